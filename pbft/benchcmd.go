@@ -0,0 +1,338 @@
+// Load generator and benchmark of a running cluster.
+//
+// Each of the clients (<prefix>1 to <prefix>n, with their keys, in the
+// client list of the nodes) has one outstanding request at a time, as
+// in TOCS. Without a rate, the clients send their next request as soon
+// as the previous one completes (closed loop). With a rate, requests
+// are issued at that rate to the idle clients (open loop); requests due
+// while all clients are busy are not sent, and counted as such.
+//
+// Operations are drawn from the mix: Put writes a value of the request
+// size to one of the keys, Get reads one, and any other operation (e.g.,
+// "Op1") carries data of the request size and is only ordered.
+//
+// The latency of a request is from its issue to f+1 matching replies.
+// View changes are counted from the views of the replies. The history
+// of the operations can be saved for the linearizability check.
+//
+//   ./pbft bench -clients 4 -size 1K -mix Put=1,Get=1 -duration 30s
+//   ./pbft bench -clients 4 -rate 200 -history bench.json
+//   ./pbft check node.list bench.json
+
+package main
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/checker"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/client"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operation with its weight in the mix.
+type benchOp struct {
+	name   string
+	weight int
+}
+
+// Outcome of one request.
+type benchResult struct {
+	op      checker.Operation
+	latency time.Duration
+	viewID  int64
+	err     error
+}
+
+// bench [options]
+func bench(args []string) {
+	var options clientOptions
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	flags.StringVar(&options.config, "config", "node.list", "genesis document or node list of the cluster")
+	flags.DurationVar(&options.timeout, "timeout", client.DefaultTimeout, "time to wait for replies before retransmitting a request")
+	clients := flags.Int("clients", 1, "number of clients")
+	prefix := flags.String("prefix", "Client", "name prefix of the clients")
+	size := flags.String("size", "1K", "bytes of data of each request, e.g., 512, 4K or 1M")
+	rate := flags.Float64("rate", 0, "requests per second (open loop), or 0 for closed loop")
+	mix := flags.String("mix", "Put=1,Get=1", "operations with their weights")
+	keys := flags.Int("keys", 16, "number of keys of Put and Get")
+	duration := flags.Duration("duration", 10 * time.Second, "time to issue requests")
+	wait := flags.Duration("wait", 30 * time.Second, "time to wait for the result of each request")
+	history := flags.String("history", "", "file to save the history of the operations to")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s bench [options]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	dataSize, err := parseSize(*size)
+	AssertError(err)
+	ops, err := parseMix(*mix)
+	AssertError(err)
+	if *clients < 1 || *keys < 1 || *rate < 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config := options.clientConfig()
+	benchClients := make([]*client.Client, *clients)
+	for i := range benchClients {
+		benchClients[i] = connectClient(config, fmt.Sprintf("%s%d", *prefix, i + 1))
+		defer benchClients[i].Close()
+	}
+
+	// Requests issued in open loop, with their issue time.
+	var arrivals chan time.Time
+	skipped := 0
+	if *rate > 0 {
+		arrivals = make(chan time.Time, *clients)
+	}
+
+	var resultsMutex sync.Mutex
+	var results []*benchResult
+	var wg sync.WaitGroup
+
+	fmt.Printf("Benchmark: %d clients, %d bytes, %s, mix %s, for %v\n",
+	           *clients, dataSize, loopName(*rate), *mix, *duration)
+	start := time.Now()
+	deadline := start.Add(*duration)
+
+	for i, c := range benchClients {
+		wg.Add(1)
+		go func(c *client.Client, clientID string, seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+
+			for {
+				issued := time.Now()
+				if arrivals != nil {
+					var ok bool
+					if issued, ok = <-arrivals; !ok {
+						return
+					}
+				} else if !issued.Before(deadline) {
+					return
+				}
+
+				operation, data := nextBenchOp(rng, ops, *keys, dataSize)
+				result := invokeBenchOp(c, clientID, operation, data, issued, *wait)
+
+				resultsMutex.Lock()
+				results = append(results, result)
+				resultsMutex.Unlock()
+			}
+		}(c, fmt.Sprintf("%s%d", *prefix, i + 1), start.UnixNano() + int64(i))
+	}
+
+	if arrivals != nil {
+		interval := time.Duration(float64(time.Second) / *rate)
+		ticker := time.NewTicker(interval)
+		timer := time.NewTimer(*duration)
+	issue:
+		for {
+			select {
+			case now := <-ticker.C:
+				select {
+				case arrivals <- now:
+				default:
+					skipped++
+				}
+			case <-timer.C:
+				break issue
+			}
+		}
+		ticker.Stop()
+		close(arrivals)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	reportBench(results, skipped, elapsed)
+
+	if *history != "" {
+		ops := make([]checker.Operation, len(results))
+		for i, result := range results {
+			ops[i] = result.op
+		}
+		sort.SliceStable(ops, func(i, j int) bool {
+			return ops[i].Call < ops[j].Call
+		})
+
+		data, err := json.MarshalIndent(ops, "", "\t")
+		AssertError(err)
+		AssertError(os.WriteFile(*history, data, 0644))
+		fmt.Printf("History of %d operations written to %s\n", len(ops), *history)
+	}
+}
+
+// Invoke the operation, and record it for the history. Failed
+// operations are pending, as they may or may not have taken effect.
+func invokeBenchOp(c *client.Client, clientID string, operation string, data string,
+                   issued time.Time, wait time.Duration) *benchResult {
+	result := &benchResult{op: checker.Operation{
+		ClientID:  clientID,
+		Operation: operation,
+		Input:     data,
+		Call:      time.Now().UnixNano(),
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+
+	reply, err := c.Invoke(ctx, operation, data)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	result.op.Timestamp = reply.Timestamp
+	result.op.Output = reply.Result
+	result.op.Return = time.Now().UnixNano()
+	result.latency = time.Since(issued)
+	result.viewID = reply.ViewID
+
+	return result
+}
+
+// Draw an operation from the mix, with its data.
+func nextBenchOp(rng *rand.Rand, ops []*benchOp, keys int, size int) (string, string) {
+	total := 0
+	for _, op := range ops {
+		total += op.weight
+	}
+	n := rng.Intn(total)
+	var name string
+	for _, op := range ops {
+		if n -= op.weight; n < 0 {
+			name = op.name
+			break
+		}
+	}
+
+	key := "k" + strconv.Itoa(rng.Intn(keys))
+	switch name {
+	case checker.OpPut:
+		return name, key + "=" + randomData(rng, size)
+	case checker.OpGet:
+		return name, key
+	}
+
+	return name, randomData(rng, size)
+}
+
+func randomData(rng *rand.Rand, size int) string {
+	const letters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = letters[rng.Intn(len(letters))]
+	}
+
+	return string(data)
+}
+
+// Print the throughput, the latency percentiles and the view changes.
+func reportBench(results []*benchResult, skipped int, elapsed time.Duration) {
+	var latencies []time.Duration
+	failed := 0
+	minView, maxView := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+			continue
+		}
+
+		latencies = append(latencies, result.latency)
+		if result.viewID < minView {
+			minView = result.viewID
+		}
+		if result.viewID > maxView {
+			maxView = result.viewID
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	fmt.Printf("Requests:     %d completed, %d failed, %d not sent (clients busy)\n",
+	           len(latencies), failed, skipped)
+	fmt.Printf("Throughput:   %.1f requests/s\n", float64(len(latencies)) / elapsed.Seconds())
+	if len(latencies) == 0 {
+		return
+	}
+
+	percentile := func(p float64) time.Duration {
+		idx := int(math.Ceil(p * float64(len(latencies)))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		return latencies[idx].Round(time.Microsecond)
+	}
+	fmt.Printf("Latency:      p50 %v, p90 %v, p99 %v, max %v\n",
+	           percentile(0.5), percentile(0.9), percentile(0.99), percentile(1))
+	fmt.Printf("View changes: %d (view %d to %d)\n", maxView - minView, minView, maxView)
+}
+
+func loopName(rate float64) string {
+	if rate > 0 {
+		return fmt.Sprintf("%g requests/s", rate)
+	}
+
+	return "closed loop"
+}
+
+// Parse a size in bytes, with an optional K or M suffix as in send.sh.
+func parseSize(value string) (int, error) {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1 << 20
+	}
+	if multiplier > 1 {
+		value = value[:len(value) - 1]
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("size %q is not a number of bytes", value)
+	}
+
+	return n * multiplier, nil
+}
+
+// Parse the operation mix, e.g., "Put=3,Get=1".
+func parseMix(value string) ([]*benchOp, error) {
+	var ops []*benchOp
+	total := 0
+	for _, entry := range strings.Split(value, ",") {
+		name, weight, found := strings.Cut(strings.TrimSpace(entry), "=")
+		op := &benchOp{name: name, weight: 1}
+		if found {
+			var err error
+			if op.weight, err = strconv.Atoi(weight); err != nil || op.weight < 0 {
+				return nil, fmt.Errorf("weight of %s in %q is not a number", name, value)
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("operation mix %q has an empty entry", value)
+		}
+		ops = append(ops, op)
+		total += op.weight
+	}
+	if total == 0 {
+		return nil, errors.New("operation mix has no weights")
+	}
+
+	return ops, nil
+}
@@ -0,0 +1,60 @@
+#!/bin/bash
+
+RED='\033[0;31m'
+NC='\033[0m'
+
+if [[ $# -lt 1 ]]
+then
+	echo "Usage: $0 <number of nodes> [client ID]"
+	echo "Example: $0 4 Client1"
+
+	exit
+fi
+
+NUMNODES=$1
+CLIENTID=${2:-Client1}
+CERTPATH="certs"
+DAYS=3650
+
+# Remove existing certificates.
+rm -r $CERTPATH
+
+# Create directory for new certificates.
+mkdir -p $CERTPATH
+exitcode=$?
+if [[ $exitcode -ne 0 ]] || [[ ! -d $CERTPATH ]]
+then
+        echo "Certificate directory $CERTPATH cannot be accessed!"
+        exit
+fi
+
+# Self-signed certificate for each node. The common name must be
+# the node ID; the certificates are pinned by the other nodes.
+for i in `seq 1 $NUMNODES`
+do
+	openssl req -x509 -newkey ec -pkeyopt ec_paramgen_curve:prime256v1 -nodes \
+		-keyout "$CERTPATH/Node$i.key" -out "$CERTPATH/Node$i.crt" -days $DAYS \
+		-subj "/CN=Node$i" \
+		-addext "subjectAltName=DNS:localhost,IP:127.0.0.1" 2>/dev/null &
+done
+
+# Separate CA for client certificates.
+openssl req -x509 -newkey ec -pkeyopt ec_paramgen_curve:prime256v1 -nodes \
+	-keyout "$CERTPATH/client-ca.key" -out "$CERTPATH/client-ca.crt" -days $DAYS \
+	-subj "/CN=PBFT Client CA" \
+	-addext "basicConstraints=critical,CA:TRUE" \
+	-addext "keyUsage=critical,keyCertSign" 2>/dev/null
+
+# Client certificate. The common name is the client ID.
+openssl req -newkey ec -pkeyopt ec_paramgen_curve:prime256v1 -nodes \
+	-keyout "$CERTPATH/client.key" -out "$CERTPATH/client.csr" \
+	-subj "/CN=$CLIENTID" 2>/dev/null
+openssl x509 -req -in "$CERTPATH/client.csr" -days $DAYS \
+	-CA "$CERTPATH/client-ca.crt" -CAkey "$CERTPATH/client-ca.key" -CAcreateserial \
+	-extfile <(printf "extendedKeyUsage=clientAuth") \
+	-out "$CERTPATH/client.crt" 2>/dev/null
+rm -f "$CERTPATH/client.csr"
+
+wait
+
+printf "${RED}$NUMNODES node certificates and a certificate for $CLIENTID created!${NC}\n"
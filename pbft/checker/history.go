@@ -0,0 +1,133 @@
+// Client histories for the linearizability checker.
+
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Operation is one client invocation and, if it completed, its response.
+// Call and Return are wall-clock timestamps (UnixNano) taken by the client.
+type Operation struct {
+	ClientID  string `json:"clientID"`
+	Timestamp int64  `json:"timestamp"` // same timestamp value as RequestMsg
+	Operation string `json:"operation"`
+	Input     string `json:"input"`
+	Output    string `json:"output"`
+	Call      int64  `json:"call"`
+	Return    int64  `json:"return"` // zero while the operation is pending
+}
+
+func (op *Operation) Pending() bool {
+	return op.Return == 0
+}
+
+// History records client operations. It is safe for concurrent use.
+type History struct {
+	mutex sync.Mutex
+	ops   []*Operation
+	// key: clientID and timestamp of the request
+	pending map[string]*Operation
+}
+
+func NewHistory() *History {
+	return &History{
+		ops:     make([]*Operation, 0),
+		pending: make(map[string]*Operation),
+	}
+}
+
+func opKey(clientID string, timestamp int64) string {
+	return fmt.Sprintf("%s/%d", clientID, timestamp)
+}
+
+// Invoke records that a client sent a request.
+func (h *History) Invoke(clientID string, timestamp int64, operation string, input string) {
+	op := &Operation{
+		ClientID:  clientID,
+		Timestamp: timestamp,
+		Operation: operation,
+		Input:     input,
+		Call:      time.Now().UnixNano(),
+	}
+
+	h.mutex.Lock()
+	h.ops = append(h.ops, op)
+	h.pending[opKey(clientID, timestamp)] = op
+	h.mutex.Unlock()
+}
+
+// Return records the result the client accepted for its request.
+// It reports false if the request was never invoked or already returned.
+func (h *History) Return(clientID string, timestamp int64, output string) bool {
+	key := opKey(clientID, timestamp)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	op, ok := h.pending[key]
+	if !ok {
+		return false
+	}
+	delete(h.pending, key)
+
+	op.Output = output
+	op.Return = time.Now().UnixNano()
+
+	return true
+}
+
+// IsPending reports whether the request is invoked but not returned yet.
+func (h *History) IsPending(clientID string, timestamp int64) bool {
+	h.mutex.Lock()
+	_, ok := h.pending[opKey(clientID, timestamp)]
+	h.mutex.Unlock()
+
+	return ok
+}
+
+// Operations returns a copy of the recorded operations ordered by call time.
+func (h *History) Operations() []Operation {
+	h.mutex.Lock()
+	ops := make([]Operation, len(h.ops))
+	for i, op := range h.ops {
+		ops[i] = *op
+	}
+	h.mutex.Unlock()
+
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].Call < ops[j].Call
+	})
+
+	return ops
+}
+
+// Save writes the recorded operations as JSON.
+func (h *History) Save(path string) error {
+	data, err := json.MarshalIndent(h.Operations(), "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadHistory reads operations written by Save.
+func LoadHistory(path string) ([]Operation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []Operation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, err
+	}
+
+	return ops, nil
+}
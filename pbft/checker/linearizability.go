@@ -0,0 +1,163 @@
+// Linearizability check for key-value histories.
+//
+// Operations on different keys are independent, so the history is
+// partitioned by key and each partition is searched separately
+// (Wing & Gong, with the memoization from Lowe).
+
+package checker
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Operations understood by the key-value state machine of the replicas.
+const (
+	OpPut = "Put" // input: "key=value", output: "OK"
+	OpGet = "Get" // input: "key", output: value, or "" if absent
+)
+
+// Result of operations that are not key-value operations.
+const ResultExecuted = "Executed"
+
+// Key returns the key an operation accesses, and false if the
+// operation is not a key-value operation.
+func (op *Operation) Key() (string, bool) {
+	switch op.Operation {
+	case OpPut:
+		key, _ := SplitPut(op.Input)
+		return key, true
+	case OpGet:
+		return op.Input, true
+	}
+
+	return "", false
+}
+
+// SplitPut splits the input of PUT operation into key and value.
+func SplitPut(input string) (string, string) {
+	idx := strings.IndexByte(input, '=')
+	if idx < 0 {
+		return input, ""
+	}
+
+	return input[:idx], input[idx+1:]
+}
+
+// step applies op to the register holding value and reports whether the
+// output observed by the client is possible, along with the new value.
+func step(value string, op *Operation) (bool, string) {
+	switch op.Operation {
+	case OpPut:
+		_, newValue := SplitPut(op.Input)
+		return op.Pending() || op.Output == "OK", newValue
+	case OpGet:
+		return op.Pending() || op.Output == value, value
+	}
+
+	return false, value
+}
+
+// CheckLinearizable reports an error describing the first key whose
+// sub-history is not linearizable. Pending operations may or may not
+// have taken effect. Operations other than key-value operations must
+// have returned ResultExecuted.
+func CheckLinearizable(ops []Operation) error {
+	partitions := make(map[string][]*Operation)
+	keys := make([]string, 0)
+
+	for i := range ops {
+		op := &ops[i]
+		key, ok := op.Key()
+		if !ok {
+			if !op.Pending() && op.Output != ResultExecuted {
+				return fmt.Errorf("%s from %s (timestamp %d) returned %q",
+				                  op.Operation, op.ClientID, op.Timestamp, op.Output)
+			}
+			continue
+		}
+
+		if _, ok := partitions[key]; !ok {
+			keys = append(keys, key)
+		}
+		partitions[key] = append(partitions[key], op)
+	}
+
+	for _, key := range keys {
+		if !linearizable(partitions[key]) {
+			return fmt.Errorf("history of key %q is not linearizable (%d operations)",
+			                  key, len(partitions[key]))
+		}
+	}
+
+	return nil
+}
+
+type searchState struct {
+	done  string // bitmap of linearized operations
+	value string
+}
+
+func linearizable(ops []*Operation) bool {
+	returns := make([]int64, len(ops))
+	completed := 0
+	for i, op := range ops {
+		if op.Pending() {
+			returns[i] = math.MaxInt64
+		} else {
+			returns[i] = op.Return
+			completed++
+		}
+	}
+
+	done := make([]byte, (len(ops)+7)/8)
+	visited := make(map[searchState]bool)
+
+	var search func(value string, left int) bool
+	search = func(value string, left int) bool {
+		if left == 0 {
+			return true
+		}
+
+		key := searchState{string(done), value}
+		if visited[key] {
+			return false
+		}
+		visited[key] = true
+
+		// Only operations invoked before the earliest response among
+		// the remaining operations can be linearized next.
+		minReturn := int64(math.MaxInt64)
+		for i := range ops {
+			if done[i/8]&(1<<(i%8)) == 0 && returns[i] < minReturn {
+				minReturn = returns[i]
+			}
+		}
+
+		for i, op := range ops {
+			if done[i/8]&(1<<(i%8)) != 0 || op.Call > minReturn {
+				continue
+			}
+
+			ok, newValue := step(value, op)
+			if !ok {
+				continue
+			}
+
+			done[i/8] |= 1 << (i % 8)
+			newLeft := left
+			if !op.Pending() {
+				newLeft--
+			}
+			if search(newValue, newLeft) {
+				return true
+			}
+			done[i/8] &^= 1 << (i % 8)
+		}
+
+		return false
+	}
+
+	return search("", completed)
+}
@@ -0,0 +1,176 @@
+package checker
+
+import (
+	"testing"
+)
+
+// Completed operation, with its call and return times.
+func completed(clientID string, operation string, input string, output string, call int64, ret int64) Operation {
+	return Operation{ClientID: clientID, Operation: operation, Input: input, Output: output, Call: call, Return: ret}
+}
+
+// Operation without a response.
+func pending(clientID string, operation string, input string, call int64) Operation {
+	return Operation{ClientID: clientID, Operation: operation, Input: input, Call: call}
+}
+
+func TestCheckLinearizable(t *testing.T) {
+	tests := []struct {
+		name         string
+		ops          []Operation
+		linearizable bool
+	}{
+		{
+			name:         "empty history",
+			ops:          nil,
+			linearizable: true,
+		},
+		{
+			name: "sequential put and get",
+			ops: []Operation{
+				completed("Client1", OpPut, "k=1", "OK", 1, 2),
+				completed("Client1", OpGet, "k", "1", 3, 4),
+			},
+			linearizable: true,
+		},
+		{
+			name: "get of an absent key",
+			ops: []Operation{
+				completed("Client1", OpGet, "k", "", 1, 2),
+			},
+			linearizable: true,
+		},
+		{
+			name: "stale read after a completed put",
+			ops: []Operation{
+				completed("Client1", OpPut, "k=1", "OK", 1, 2),
+				completed("Client1", OpPut, "k=2", "OK", 3, 4),
+				completed("Client2", OpGet, "k", "1", 5, 6),
+			},
+			linearizable: false,
+		},
+		{
+			name: "read of a value never written",
+			ops: []Operation{
+				completed("Client1", OpPut, "k=1", "OK", 1, 2),
+				completed("Client2", OpGet, "k", "3", 3, 4),
+			},
+			linearizable: false,
+		},
+		{
+			name: "concurrent put seen by an overlapping get",
+			ops: []Operation{
+				completed("Client1", OpPut, "k=1", "OK", 1, 10),
+				completed("Client2", OpGet, "k", "1", 2, 5),
+			},
+			linearizable: true,
+		},
+		{
+			name: "concurrent put not yet seen by an overlapping get",
+			ops: []Operation{
+				completed("Client1", OpPut, "k=1", "OK", 1, 10),
+				completed("Client2", OpGet, "k", "", 2, 5),
+			},
+			linearizable: true,
+		},
+		{
+			name: "reads observe concurrent puts in opposite orders",
+			ops: []Operation{
+				completed("Client1", OpPut, "k=1", "OK", 1, 10),
+				completed("Client2", OpPut, "k=2", "OK", 1, 10),
+				completed("Client3", OpGet, "k", "1", 11, 12),
+				completed("Client3", OpGet, "k", "2", 13, 14),
+				completed("Client4", OpGet, "k", "1", 15, 16),
+			},
+			linearizable: false,
+		},
+		{
+			name: "pending put which took effect",
+			ops: []Operation{
+				pending("Client1", OpPut, "k=1", 1),
+				completed("Client2", OpGet, "k", "1", 5, 6),
+			},
+			linearizable: true,
+		},
+		{
+			name: "pending put which did not take effect",
+			ops: []Operation{
+				pending("Client1", OpPut, "k=1", 1),
+				completed("Client2", OpGet, "k", "", 5, 6),
+			},
+			linearizable: true,
+		},
+		{
+			name: "pending put cannot take effect before its call",
+			ops: []Operation{
+				completed("Client2", OpGet, "k", "1", 1, 2),
+				pending("Client1", OpPut, "k=1", 5),
+			},
+			linearizable: false,
+		},
+		{
+			name: "keys are independent",
+			ops: []Operation{
+				completed("Client1", OpPut, "a=1", "OK", 1, 2),
+				completed("Client1", OpPut, "b=2", "OK", 3, 4),
+				completed("Client2", OpGet, "a", "1", 5, 6),
+				completed("Client2", OpGet, "b", "2", 7, 8),
+			},
+			linearizable: true,
+		},
+		{
+			name: "failed put",
+			ops: []Operation{
+				completed("Client1", OpPut, "k=1", "Error", 1, 2),
+			},
+			linearizable: false,
+		},
+		{
+			name: "other operations executed",
+			ops: []Operation{
+				completed("Client1", "Op1", "data", ResultExecuted, 1, 2),
+				pending("Client1", "Op2", "data", 3),
+			},
+			linearizable: true,
+		},
+		{
+			name: "other operation with an unexpected result",
+			ops: []Operation{
+				completed("Client1", "Op1", "data", "OK", 1, 2),
+			},
+			linearizable: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckLinearizable(test.ops)
+			if test.linearizable && err != nil {
+				t.Errorf("history is reported not linearizable: %v", err)
+			}
+			if !test.linearizable && err == nil {
+				t.Errorf("history is reported linearizable")
+			}
+		})
+	}
+}
+
+func TestSplitPut(t *testing.T) {
+	tests := []struct {
+		input string
+		key   string
+		value string
+	}{
+		{"k=v", "k", "v"},
+		{"k=", "k", ""},
+		{"k", "k", ""},
+		{"k=a=b", "k", "a=b"},
+	}
+
+	for _, test := range tests {
+		key, value := SplitPut(test.input)
+		if key != test.key || value != test.value {
+			t.Errorf("SplitPut(%q) = %q, %q, want %q, %q", test.input, key, value, test.key, test.value)
+		}
+	}
+}
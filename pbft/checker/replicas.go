@@ -0,0 +1,98 @@
+// Cross-replica agreement on the committed log.
+
+package checker
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+)
+
+// CommittedEntry is one entry of the log of executed requests of a replica.
+type CommittedEntry struct {
+	SequenceID int64  `json:"sequenceID"`
+	Digest     string `json:"digest"`
+	ClientID   string `json:"clientID"`
+	Timestamp  int64  `json:"timestamp"`
+	Operation  string `json:"operation"`
+}
+
+func NewCommittedEntry(request *consensus.RequestMsg, digest string) CommittedEntry {
+	return CommittedEntry{
+		SequenceID: request.SequenceID,
+		Digest:     digest,
+		ClientID:   request.ClientID,
+		Timestamp:  request.Timestamp,
+		Operation:  request.Operation,
+	}
+}
+
+// CheckCommittedPrefix reports an error if the committed logs of two
+// replicas differ anywhere in their common prefix.
+// key: nodeID, value: committed log of the node
+func CheckCommittedPrefix(logs map[string][]CommittedEntry) error {
+	nodeIDs := make([]string, 0, len(logs))
+	for nodeID := range logs {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	// Compare every log with the longest one.
+	longest := ""
+	for _, nodeID := range nodeIDs {
+		if longest == "" || len(logs[nodeID]) > len(logs[longest]) {
+			longest = nodeID
+		}
+	}
+
+	for _, nodeID := range nodeIDs {
+		for i, entry := range logs[nodeID] {
+			ref := logs[longest][i]
+			if entry != ref {
+				return fmt.Errorf("committed logs of %s and %s diverge at index %d: %+v != %+v",
+				                  nodeID, longest, i, entry, ref)
+			}
+		}
+	}
+
+	return nil
+}
+
+var httpClient = &http.Client{Timeout: time.Second * 5}
+var scheme = "http"
+
+// SetTLSConfig makes the checker connect to the replicas over HTTPS.
+func SetTLSConfig(config *tls.Config) {
+	httpClient = &http.Client{
+		Timeout:   time.Second * 5,
+		Transport: &http.Transport{TLSClientConfig: config},
+	}
+	scheme = "https"
+}
+
+func fetchJSON(url string, v interface{}) error {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// FetchCommitted gets the committed log from the replica at addr (host:port).
+func FetchCommitted(addr string) ([]CommittedEntry, error) {
+	var entries []CommittedEntry
+	err := fetchJSON(scheme+"://"+addr+"/committed", &entries)
+
+	return entries, err
+}
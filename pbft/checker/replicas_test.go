@@ -0,0 +1,108 @@
+package checker
+
+import (
+	"fmt"
+	"testing"
+)
+
+func entry(sequenceID int64, clientID string, timestamp int64) CommittedEntry {
+	return CommittedEntry{
+		SequenceID: sequenceID,
+		Digest:     fmt.Sprintf("%s/%d", clientID, timestamp),
+		ClientID:   clientID,
+		Timestamp:  timestamp,
+		Operation:  OpPut,
+	}
+}
+
+func TestCheckCommittedPrefix(t *testing.T) {
+	e1 := entry(1, "Client1", 1)
+	e2 := entry(2, "Client2", 1)
+	e3 := entry(3, "Client1", 2)
+	other := entry(2, "Client3", 1)
+
+	tests := []struct {
+		name  string
+		logs  map[string][]CommittedEntry
+		agree bool
+	}{
+		{
+			name:  "no replicas",
+			logs:  map[string][]CommittedEntry{},
+			agree: true,
+		},
+		{
+			name: "empty logs",
+			logs: map[string][]CommittedEntry{
+				"Node1": nil,
+				"Node2": nil,
+			},
+			agree: true,
+		},
+		{
+			name: "identical logs",
+			logs: map[string][]CommittedEntry{
+				"Node1": {e1, e2, e3},
+				"Node2": {e1, e2, e3},
+				"Node3": {e1, e2, e3},
+			},
+			agree: true,
+		},
+		{
+			name: "lagging replicas",
+			logs: map[string][]CommittedEntry{
+				"Node1": {e1, e2, e3},
+				"Node2": {e1},
+				"Node3": nil,
+				"Node4": {e1, e2},
+			},
+			agree: true,
+		},
+		{
+			name: "diverging replica",
+			logs: map[string][]CommittedEntry{
+				"Node1": {e1, e2, e3},
+				"Node2": {e1, other},
+			},
+			agree: false,
+		},
+		{
+			name: "diverging longest replica",
+			logs: map[string][]CommittedEntry{
+				"Node1": {e1, e2},
+				"Node2": {e1, e2},
+				"Node3": {e1, other, e3},
+			},
+			agree: false,
+		},
+		{
+			name: "lagging replicas diverging from each other",
+			logs: map[string][]CommittedEntry{
+				"Node1": {e1, e2, e3},
+				"Node2": {e1, e2},
+				"Node3": {e1, other},
+			},
+			agree: false,
+		},
+		{
+			name: "same request under another digest",
+			logs: map[string][]CommittedEntry{
+				"Node1": {e1, e2},
+				"Node2": {e1, {SequenceID: 2, Digest: "forged", ClientID: "Client2", Timestamp: 1, Operation: OpPut}},
+			},
+			agree: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckCommittedPrefix(test.logs)
+			if test.agree && err != nil {
+				t.Errorf("logs are reported to diverge: %v", err)
+			}
+			if !test.agree && err == nil {
+				t.Errorf("logs are reported to agree")
+			}
+		})
+	}
+}
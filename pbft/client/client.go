@@ -0,0 +1,335 @@
+// Package client submits signed requests to the replicas and waits for
+// their result.
+//
+// From TOCS: A client c requests the execution of state machine
+// operation o by sending a <REQUEST, o, t, c> message to the primary.
+// The client waits for f+1 replies with valid signatures from
+// different replicas, and with the same t and r, before accepting the
+// result r. If the client does not receive replies soon enough, it
+// broadcasts the request to all replicas.
+//
+// Requests are submitted with the Submit RPC of the replicas, so every
+// replica needs a gRPC address. Replies are received from the hub of
+// each replica, which sends a client the replies to its own requests.
+// A primary that does not admit a request now answers that it is busy,
+// and the client submits the request again when told.
+package client
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/network"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/pbftpb"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Requests are retransmitted to all replicas after this timeout, unless
+// configured otherwise.
+const DefaultTimeout = time.Second
+
+type Config struct {
+	ClusterID string
+	ClientID  string
+	Signer    consensus.Signer
+
+	// Replicas with their public keys and gRPC addresses.
+	NodeTable []*network.NodeInfo
+
+	// Mutual TLS with the replicas (see network.LoadClientTLSConfig),
+	// or nil for plain connections.
+	TLSConfig *tls.Config
+
+	// Time to wait for replies before retransmitting a request to
+	// all replicas.
+	Timeout time.Duration
+
+	// View the client starts with; it follows the view of the
+	// replicas from their replies.
+	View int64
+}
+
+// Reply accepted from f+1 replicas.
+type Reply struct {
+	Timestamp int64    `json:"timestamp"`
+	Result    string   `json:"result"`
+	ViewID    int64    `json:"viewID"`
+	Replicas  []string `json:"replicas"` // replicas which sent the result
+}
+
+type Client struct {
+	config Config
+	keys   *network.KeyRing
+	f      int
+
+	// Submit RPC of each replica, and its Nonce RPC for the signed
+	// metadata without TLS. key: nodeID
+	submitters map[string]pbftpb.ClientClient
+	handshakes map[string]pbftpb.HandshakeClient
+	conns      []*grpc.ClientConn
+
+	// Verified replies to this client from all replicas.
+	replies chan *consensus.ReplyMsg
+
+	// As in TOCS, a client has one outstanding request at a time.
+	invokeMutex   sync.Mutex
+	lastTimestamp int64
+
+	viewMutex sync.RWMutex
+	view      int64
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Number of replies buffered until the client reads them.
+const replyQueueSize = 256
+
+// New connects to the replicas and subscribes to their replies.
+func New(config Config) (*Client, error) {
+	if config.ClientID == "" || config.Signer == nil {
+		return nil, errors.New("client ID and signer are required")
+	}
+	if len(config.NodeTable) == 0 {
+		return nil, errors.New("no replicas")
+	}
+	if config.ClusterID == "" {
+		config.ClusterID = network.DefaultClusterID
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	client := &Client{
+		config:     config,
+		keys:       network.NewKeyRing(config.NodeTable),
+		f:          (len(config.NodeTable) - 1) / 3,
+		submitters: make(map[string]pbftpb.ClientClient),
+		handshakes: make(map[string]pbftpb.HandshakeClient),
+		replies:    make(chan *consensus.ReplyMsg, replyQueueSize),
+		view:       config.View,
+		closed:     make(chan struct{}),
+	}
+
+	creds := insecure.NewCredentials()
+	if config.TLSConfig != nil {
+		creds = credentials.NewTLS(config.TLSConfig)
+	}
+	for _, nodeInfo := range config.NodeTable {
+		if nodeInfo.GrpcUrl == "" {
+			client.Close()
+			return nil, fmt.Errorf("node '%s' has no gRPC address", nodeInfo.NodeID)
+		}
+
+		conn, err := grpc.NewClient(nodeInfo.GrpcUrl, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("gRPC client for %s: %v", nodeInfo.NodeID, err)
+		}
+		client.conns = append(client.conns, conn)
+		client.submitters[nodeInfo.NodeID] = pbftpb.NewClientClient(conn)
+		client.handshakes[nodeInfo.NodeID] = pbftpb.NewHandshakeClient(conn)
+	}
+
+	for _, nodeInfo := range config.NodeTable {
+		go client.subscribe(nodeInfo)
+	}
+
+	return client, nil
+}
+
+// Close disconnects from the replicas.
+func (client *Client) Close() error {
+	client.closeOnce.Do(func() {
+		close(client.closed)
+		for _, conn := range client.conns {
+			conn.Close()
+		}
+	})
+
+	return nil
+}
+
+// View is the latest view of the replicas known to the client.
+func (client *Client) View() int64 {
+	client.viewMutex.RLock()
+	defer client.viewMutex.RUnlock()
+
+	return client.view
+}
+
+// Primary of the latest known view.
+func (client *Client) Primary() *network.NodeInfo {
+	nodeTable := client.config.NodeTable
+
+	return nodeTable[client.View() % int64(len(nodeTable))]
+}
+
+// Invoke signs a request for the operation, submits it to the primary,
+// and waits until f+1 replicas reply with the same result. It
+// retransmits the request to all replicas whenever the replies do not
+// arrive in time, until the context is done.
+func (client *Client) Invoke(ctx context.Context, operation string, data string) (*Reply, error) {
+	client.invokeMutex.Lock()
+	defer client.invokeMutex.Unlock()
+
+	// Timestamps of a client are increasing, so replicas can tell
+	// a new request from a retransmitted one.
+	timestamp := time.Now().UnixNano()
+	if timestamp <= client.lastTimestamp {
+		timestamp = client.lastTimestamp + 1
+	}
+	client.lastTimestamp = timestamp
+
+	request := &consensus.RequestMsg{
+		Timestamp: timestamp,
+		ClientID:  client.config.ClientID,
+		Operation: operation,
+		Data:      data,
+	}
+	if err := request.Sign(client.config.ClusterID, client.config.Signer); err != nil {
+		return nil, err
+	}
+
+	// A busy primary is asked again once it may admit the request.
+	for {
+		err := client.submit(ctx, client.Primary(), request)
+		busy, ok := err.(*network.BusyError)
+		if !ok {
+			if err != nil {
+				client.broadcast(ctx, request)
+			}
+			break
+		}
+
+		select {
+		case <-time.After(busy.RetryAfter):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-client.closed:
+			return nil, errors.New("client is closed")
+		}
+	}
+
+	timer := time.NewTimer(client.config.Timeout)
+	defer timer.Stop()
+
+	// key: result, value: (key: nodeID, value: view of the replica)
+	votes := make(map[string]map[string]int64)
+
+	for {
+		select {
+		case reply := <-client.replies:
+			if reply.Timestamp != timestamp {
+				continue
+			}
+
+			if votes[reply.Result] == nil {
+				votes[reply.Result] = make(map[string]int64)
+			}
+			votes[reply.Result][reply.NodeID] = reply.ViewID
+
+			if len(votes[reply.Result]) >= client.f + 1 {
+				return client.accept(timestamp, reply.Result, votes[reply.Result]), nil
+			}
+		case <-timer.C:
+			client.broadcast(ctx, request)
+			timer.Reset(client.config.Timeout)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-client.closed:
+			return nil, errors.New("client is closed")
+		}
+	}
+}
+
+// Accept the result, and move on to the highest view that at least
+// f+1 of the replicas have reached, as one of them is correct.
+func (client *Client) accept(timestamp int64, result string, views map[string]int64) *Reply {
+	reply := &Reply{Timestamp: timestamp, Result: result}
+
+	viewIDs := make([]int64, 0, len(views))
+	for nodeID, viewID := range views {
+		reply.Replicas = append(reply.Replicas, nodeID)
+		viewIDs = append(viewIDs, viewID)
+	}
+	sort.Strings(reply.Replicas)
+	sort.Slice(viewIDs, func(i, j int) bool { return viewIDs[i] > viewIDs[j] })
+	reply.ViewID = viewIDs[client.f]
+
+	client.viewMutex.Lock()
+	if reply.ViewID > client.view {
+		client.view = reply.ViewID
+	}
+	client.viewMutex.Unlock()
+
+	return reply
+}
+
+// Submit the request to the replica. Returns a network.BusyError if
+// the replica does not admit it now.
+func (client *Client) submit(ctx context.Context, nodeInfo *network.NodeInfo, request *consensus.RequestMsg) error {
+	ctx, cancel := context.WithTimeout(ctx, client.config.Timeout)
+	defer cancel()
+
+	// Replicas without TLS identify the caller by signed metadata,
+	// with a nonce the replica accepts once.
+	if client.config.TLSConfig == nil {
+		nonce, err := client.handshakes[nodeInfo.NodeID].Nonce(ctx, &pbftpb.NonceRequest{})
+		if err != nil {
+			return err
+		}
+		md, err := network.CallMetadata(client.config.ClusterID, client.config.ClientID, nodeInfo.NodeID,
+		                                nonce.GetNonce(), client.config.Signer)
+		if err != nil {
+			return err
+		}
+		ctx = metadata.NewOutgoingContext(ctx, md)
+	}
+
+	var header metadata.MD
+	_, err := client.submitters[nodeInfo.NodeID].Submit(ctx, &pbftpb.RequestMsg{
+		Timestamp: request.Timestamp,
+		ClientId:  request.ClientID,
+		Operation: request.Operation,
+		Data:      request.Data,
+		Signature: request.Signature,
+	}, grpc.Header(&header))
+
+	if status.Code(err) == codes.ResourceExhausted {
+		busy := &network.BusyError{Reason: status.Convert(err).Message(), RetryAfter: client.config.Timeout}
+		if values := header.Get(network.RetryAfterHeader); len(values) > 0 {
+			if ms, err := strconv.ParseInt(values[0], 10, 64); err == nil {
+				busy.RetryAfter = time.Duration(ms) * time.Millisecond
+			}
+		}
+		return busy
+	}
+
+	return err
+}
+
+// Retransmit the request to all replicas.
+func (client *Client) broadcast(ctx context.Context, request *consensus.RequestMsg) {
+	var wg sync.WaitGroup
+	for _, nodeInfo := range client.config.NodeTable {
+		wg.Add(1)
+		go func(nodeInfo *network.NodeInfo) {
+			defer wg.Done()
+			client.submit(ctx, nodeInfo, request)
+		}(nodeInfo)
+	}
+	wg.Wait()
+}
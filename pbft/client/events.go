@@ -0,0 +1,138 @@
+package client
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/network"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Follow streams the requests executed by the replica from the given
+// sequence number on, and passes each verified event to handle in
+// sequence number order. When the connection is lost, it resubscribes
+// from the sequence number after the last event, so no event is missed
+// or repeated. It returns when the context is done, the client is
+// closed, handle returns an error, or the replica no longer keeps the
+// events (see network/events.go).
+//
+// The certificate of each event is verified with the keys of the
+// replicas, so a faulty replica cannot forge events, though it may
+// withhold them.
+func (client *Client) Follow(ctx context.Context, nodeID string, from int64, handle func(*network.CommitEvent) error) error {
+	var nodeInfo *network.NodeInfo
+	for _, info := range client.config.NodeTable {
+		if info.NodeID == nodeID {
+			nodeInfo = info
+		}
+	}
+	if nodeInfo == nil {
+		return fmt.Errorf("unknown replica '%s'", nodeID)
+	}
+	if from < 1 {
+		from = 1
+	}
+	backoff := minSubscribeBackoff
+
+	for {
+		c, err := client.dial(nodeInfo, "/events", "from=" + strconv.FormatInt(from, 10))
+		if err == nil {
+			backoff = minSubscribeBackoff
+
+			// Unblock the reader when the context is done.
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+					c.Close()
+				case <-client.closed:
+					c.Close()
+				case <-done:
+				}
+			}()
+
+			from, err = client.receiveEvents(c, nodeInfo, from, handle)
+			close(done)
+			c.Close()
+			if err != nil {
+				return err
+			}
+		} else if dialErr, ok := err.(*dialError); ok && dialErr.status == http.StatusGone {
+			return fmt.Errorf("%s no longer keeps the events from %d", nodeID, from)
+		} else {
+			log.Printf("subscription to events of %s: %v", nodeID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-client.closed:
+			return fmt.Errorf("client is closed")
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxSubscribeBackoff {
+			backoff = maxSubscribeBackoff
+		}
+	}
+}
+
+// Pass the events from the replica to handle until the connection is
+// lost. Returns the sequence number to resubscribe from, and the error
+// of handle.
+func (client *Client) receiveEvents(c *websocket.Conn, nodeInfo *network.NodeInfo, from int64,
+                                    handle func(*network.CommitEvent) error) (int64, error) {
+	for {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			return from, nil
+		}
+
+		event, err := client.openEvent(data, nodeInfo)
+		if err != nil {
+			log.Printf("%v (via %s)", err, nodeInfo.NodeID)
+			continue
+		}
+		if event.SequenceID < from {
+			continue
+		}
+
+		if err := handle(event); err != nil {
+			return from, err
+		}
+		from = event.SequenceID + 1
+	}
+}
+
+// Verify an event signed by the replica, and its certificate.
+func (client *Client) openEvent(data []byte, nodeInfo *network.NodeInfo) (*network.CommitEvent, error) {
+	var peerMsg network.PeerMsg
+	if err := json.Unmarshal(data, &peerMsg); err != nil {
+		return nil, err
+	}
+
+	sigMsg, err := network.OpenSignatureMsg(peerMsg.Msg, client.config.ClusterID, client.keys)
+	if err != nil {
+		return nil, err
+	}
+	if sigMsg.MsgType != "/event" || peerMsg.Type != "/event" || sigMsg.NodeID != nodeInfo.NodeID {
+		return nil, fmt.Errorf("%s message from %s is not an event", sigMsg.MsgType, sigMsg.NodeID)
+	}
+
+	var event network.CommitEvent
+	if err := json.Unmarshal(sigMsg.MarshalledMsg, &event); err != nil {
+		return nil, err
+	}
+	if event.NodeID != sigMsg.NodeID {
+		return nil, fmt.Errorf("event from %s is sent as %s", sigMsg.NodeID, event.NodeID)
+	}
+	if err := event.Check(2*client.f + 1, client.config.ClusterID, client.keys); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
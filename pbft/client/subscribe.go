@@ -0,0 +1,148 @@
+package client
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/network"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Backoff between attempts to subscribe to a replica.
+const minSubscribeBackoff = time.Millisecond * 100
+const maxSubscribeBackoff = time.Second * 5
+
+// Keep a subscription to the hub of the replica until the client is
+// closed, and pass its replies to the client.
+func (client *Client) subscribe(nodeInfo *network.NodeInfo) {
+	backoff := minSubscribeBackoff
+
+	for {
+		c, err := client.dial(nodeInfo, "/peer", "")
+		if err == nil {
+			backoff = minSubscribeBackoff
+
+			// Unblock the reader when the client is closed.
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-client.closed:
+					c.Close()
+				case <-done:
+				}
+			}()
+
+			client.receiveReplies(c, nodeInfo)
+			close(done)
+			c.Close()
+		}
+
+		select {
+		case <-client.closed:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxSubscribeBackoff {
+			backoff = maxSubscribeBackoff
+		}
+	}
+}
+
+// Open an authenticated websocket connection to the replica.
+func (client *Client) dial(nodeInfo *network.NodeInfo, path string, query string) (*websocket.Conn, error) {
+	u := url.URL{Scheme: "ws", Host: nodeInfo.Url, Path: path, RawQuery: query}
+	dialer := websocket.DefaultDialer
+	if client.config.TLSConfig != nil {
+		u.Scheme = "wss"
+		dialer = &websocket.Dialer{
+			HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+			TLSClientConfig:  client.config.TLSConfig,
+		}
+	}
+
+	// Replicas without TLS identify the client by the handshake,
+	// signed with a nonce the replica accepts once.
+	var header http.Header
+	if client.config.TLSConfig == nil {
+		httpClient := &http.Client{Timeout: websocket.DefaultDialer.HandshakeTimeout}
+		nonce, err := network.FetchNonce(httpClient, "http://" + nodeInfo.Url)
+		if err != nil {
+			return nil, err
+		}
+		header, err = network.SubscribeHeader(client.config.ClusterID, client.config.ClientID,
+		                                      nodeInfo.NodeID, nonce, client.config.Signer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c, resp, err := dialer.Dial(u.String(), header)
+	if err != nil && resp != nil {
+		return nil, &dialError{err: err, status: resp.StatusCode}
+	}
+
+	return c, err
+}
+
+// Subscription refused by the replica with an HTTP status.
+type dialError struct {
+	err    error
+	status int
+}
+
+func (err *dialError) Error() string {
+	return fmt.Sprintf("%v (%d %s)", err.err, err.status, http.StatusText(err.status))
+}
+
+// Receive replies from the replica until the connection is lost.
+func (client *Client) receiveReplies(c *websocket.Conn, nodeInfo *network.NodeInfo) {
+	for {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		reply, err := client.openReply(data, nodeInfo)
+		if err != nil {
+			log.Printf("%v (via %s)", err, nodeInfo.NodeID)
+			continue
+		}
+
+		select {
+		case client.replies <- reply:
+		default:
+			// Replies to old requests are not read; drop them.
+		}
+	}
+}
+
+// Verify a reply to this client signed by the replica.
+func (client *Client) openReply(data []byte, nodeInfo *network.NodeInfo) (*consensus.ReplyMsg, error) {
+	var peerMsg network.PeerMsg
+	if err := json.Unmarshal(data, &peerMsg); err != nil {
+		return nil, err
+	}
+
+	sigMsg, err := network.OpenSignatureMsg(peerMsg.Msg, client.config.ClusterID, client.keys)
+	if err != nil {
+		return nil, err
+	}
+	if sigMsg.MsgType != "/reply" || peerMsg.Type != "/reply" {
+		return nil, fmt.Errorf("%s message from %s is not a reply", sigMsg.MsgType, sigMsg.NodeID)
+	}
+
+	var reply consensus.ReplyMsg
+	if err := json.Unmarshal(sigMsg.MarshalledMsg, &reply); err != nil {
+		return nil, err
+	}
+	if reply.NodeID != sigMsg.NodeID || reply.ClientID != client.config.ClientID {
+		return nil, fmt.Errorf("reply from %s is for %s, sent as %s", sigMsg.NodeID, reply.ClientID, reply.NodeID)
+	}
+
+	return &reply, nil
+}
@@ -0,0 +1,291 @@
+// Command-line client of a running cluster:
+//
+//   client submit  signs and submits operations, and prints each result
+//                  once f+1 replicas agree on it.
+//   client status  queries the status of a request at the replicas.
+//   client tail    follows the requests executed by a replica.
+//
+// The client key is read as the keys of the nodes (see keys.go), and
+// the client must be in the client list of the nodes. Operations are
+// submitted over gRPC, so the nodes need "grpcUrl" in the node list.
+//
+//   ./pbft client -id Client1 submit Put k1=v1
+//   ./pbft client -id Client1 submit -f requests.txt
+//   ./pbft client -id Client1 status 1792329243746565658
+//   ./pbft client -id Client1 tail -node Node2 -from 1
+
+package main
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/client"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/network"
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options shared by the client subcommands.
+type clientOptions struct {
+	id      string
+	config  string
+	timeout time.Duration
+}
+
+// client [-id <clientID>] [-config genesis.json|node.list] [-timeout <duration>] submit|status|tail ...
+func clientCommand(args []string) {
+	var options clientOptions
+	flags := flag.NewFlagSet("client", flag.ExitOnError)
+	flags.StringVar(&options.id, "id", "Client1", "client ID, with its key pair")
+	flags.StringVar(&options.config, "config", "node.list", "genesis document or node list of the cluster")
+	flags.DurationVar(&options.timeout, "timeout", client.DefaultTimeout, "time to wait for replies before retransmitting a request")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s client [options] submit [-f <file>] [<operation> [<data>]]\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "       %s client [options] status [-node <nodeID>] <timestamp>|-digest <digest>\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "       %s client [options] tail [-node <nodeID>] [-from <sequenceID>] [-json]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	switch flags.Arg(0) {
+	case "submit":
+		clientSubmit(&options, flags.Args()[1:])
+	case "status":
+		clientStatus(&options, flags.Args()[1:])
+	case "tail":
+		clientTail(&options, flags.Args()[1:])
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// Connect to the replicas of the cluster as the client.
+func (options *clientOptions) connect() *client.Client {
+	return connectClient(options.clientConfig(), options.id)
+}
+
+// Configuration of the clients of the cluster, without their IDs.
+func (options *clientOptions) clientConfig() client.Config {
+	nodeTable, genesis := loadClusterConfig(options.config)
+	if genesis == nil {
+		AssertError(loadPublicKeys(nodeTable, nil))
+	}
+
+	config := client.Config{
+		ClusterID: os.Getenv("PBFT_CLUSTER_ID"),
+		NodeTable: nodeTable,
+		Timeout:   options.timeout,
+		View:      viewID,
+	}
+	if genesis != nil {
+		config.ClusterID = genesis.ClusterID
+		config.View = genesis.InitialView
+	}
+	if tlsDir := os.Getenv("PBFT_TLS_DIR"); tlsDir != "" {
+		var err error
+		config.TLSConfig, err = network.LoadClientTLSConfig(tlsDir, nodeTable)
+		AssertError(err)
+	}
+
+	return config
+}
+
+// Connect to the replicas as the client with the given ID.
+func connectClient(config client.Config, clientID string) *client.Client {
+	signer, err := loadPrivateKey(clientID)
+	AssertError(err)
+
+	config.ClientID = clientID
+	config.Signer = signer
+	c, err := client.New(config)
+	AssertError(err)
+
+	return c
+}
+
+// submit [-f <file>] [-wait <duration>] [<operation> [<data>]]
+func clientSubmit(options *clientOptions, args []string) {
+	flags := flag.NewFlagSet("client submit", flag.ExitOnError)
+	file := flags.String("f", "", "file of operations, one \"<operation> [<data>]\" per line, or - for stdin")
+	wait := flags.Duration("wait", 30 * time.Second, "time to wait for the result of each operation")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s client [options] submit [-f <file>] [<operation> [<data>]]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if (*file == "") == (flags.NArg() == 0) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	c := options.connect()
+	defer c.Close()
+
+	invoke := func(operation string, data string) {
+		ctx, cancel := context.WithTimeout(context.Background(), *wait)
+		defer cancel()
+
+		reply, err := c.Invoke(ctx, operation, data)
+		if err != nil {
+			AssertError(fmt.Errorf("%s: %v", operation, err))
+		}
+
+		output, err := json.Marshal(reply)
+		AssertError(err)
+		fmt.Println(string(output))
+	}
+
+	if *file == "" {
+		invoke(flags.Arg(0), strings.Join(flags.Args()[1:], " "))
+		return
+	}
+
+	var input io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		AssertError(err)
+		defer f.Close()
+		input = f
+	}
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(nil, 4 * 1024 * 1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		operation, data, _ := strings.Cut(line, " ")
+		invoke(operation, strings.TrimSpace(data))
+	}
+	AssertError(scanner.Err())
+}
+
+// status [-node <nodeID>] <timestamp>|-digest <digest>
+func clientStatus(options *clientOptions, args []string) {
+	flags := flag.NewFlagSet("client status", flag.ExitOnError)
+	nodeID := flags.String("node", "", "replica to query, or all replicas if empty")
+	digest := flags.String("digest", "", "digest of the ordered request, instead of its timestamp")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s client [options] status [-node <nodeID>] <timestamp>|-digest <digest>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	query := url.Values{}
+	if *digest != "" {
+		query.Set("digest", *digest)
+	} else if flags.NArg() == 1 {
+		_, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+		AssertError(err)
+		query.Set("client", options.id)
+		query.Set("timestamp", flags.Arg(0))
+	} else {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	nodeTable, _ := loadClusterConfig(options.config)
+	if *nodeID != "" {
+		nodeInfo := findNodeInfo(nodeTable, *nodeID)
+		if nodeInfo == nil {
+			AssertError(fmt.Errorf("node %s is not in %s", *nodeID, options.config))
+		}
+		nodeTable = []*network.NodeInfo{nodeInfo}
+	}
+
+	httpClient, scheme := newReplicaHTTPClient(nodeTable)
+	for _, nodeInfo := range nodeTable {
+		u := url.URL{Scheme: scheme, Host: nodeInfo.Url, Path: "/status", RawQuery: query.Encode()}
+		resp, err := httpClient.Get(u.String())
+		if err != nil {
+			fmt.Printf("{\"nodeID\":%q,\"error\":%q}\n", nodeInfo.NodeID, err.Error())
+			continue
+		}
+
+		// Unknown requests are reported with their status too.
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		AssertError(err)
+		fmt.Println(strings.TrimSpace(string(body)))
+	}
+}
+
+// tail [-node <nodeID>] [-from <sequenceID>] [-json]
+func clientTail(options *clientOptions, args []string) {
+	flags := flag.NewFlagSet("client tail", flag.ExitOnError)
+	nodeID := flags.String("node", "", "replica to follow, or the first one in the node list if empty")
+	from := flags.Int64("from", 1, "sequence number of the first event")
+	asJSON := flags.Bool("json", false, "print the events as JSON, with the requests and certificates")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s client [options] tail [-node <nodeID>] [-from <sequenceID>] [-json]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	c := options.connect()
+	defer c.Close()
+
+	if *nodeID == "" {
+		nodeTable, _ := loadClusterConfig(options.config)
+		*nodeID = nodeTable[0].NodeID
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := c.Follow(ctx, *nodeID, *from, func(event *network.CommitEvent) error {
+		if *asJSON {
+			output, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(output))
+			return nil
+		}
+
+		voters := make([]string, 0, len(event.Certificate))
+		for _, commit := range event.Certificate {
+			voters = append(voters, commit.Envelope.NodeID)
+		}
+		fmt.Printf("%d view=%d digest=%s client=%s timestamp=%d operation=%s result=%s commits=%s\n",
+		           event.SequenceID, event.ViewID, event.Digest, event.Request.ClientID, event.Request.Timestamp,
+		           event.Request.Operation, event.Result, strings.Join(voters, ","))
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		AssertError(err)
+	}
+}
+
+// HTTP client of the replicas, over HTTPS with PBFT_TLS_DIR.
+func newReplicaHTTPClient(nodeTable []*network.NodeInfo) (*http.Client, string) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	tlsDir := os.Getenv("PBFT_TLS_DIR")
+	if tlsDir == "" {
+		return httpClient, "http"
+	}
+
+	tlsConfig, err := network.LoadClientTLSConfig(tlsDir, nodeTable)
+	AssertError(err)
+	httpClient.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+
+	return httpClient, "https"
+}
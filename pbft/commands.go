@@ -0,0 +1,477 @@
+// Subcommands for bootstrapping a cluster without external tools:
+//
+//   keygen    generates key pairs for node and client IDs.
+//   nodelist  writes a node list with chosen hosts and ports.
+//   validate  checks every entry of the node and client lists has a
+//             matching key pair.
+//   genesis   creates, signs and verifies the genesis document.
+//   rotatekey generates the next key of a running node and asks the
+//             node to rotate to it.
+//   client    submits requests to a running cluster, and inspects them
+//             (see clientcmd.go).
+//   bench     measures the throughput and latency of a running cluster
+//             (see benchcmd.go).
+
+package main
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/network"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// keygen [-scheme p224|p256|ed25519] [-encrypt] [-force] <ID>...
+func keygen(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	scheme := flags.String("scheme", "p224", fmt.Sprintf("key scheme, one of %v", consensus.KeySchemes))
+	encrypt := flags.Bool("encrypt", false, "encrypt private keys with a passphrase (PBFT_KEY_PASSPHRASE or prompted)")
+	force := flags.Bool("force", false, "overwrite existing keys")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s keygen [options] <ID>...\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Key pairs are written to %s as <ID>.priv and <ID>.pub.\n", keyDir())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var passphrase []byte
+	if *encrypt {
+		var err error
+		passphrase, err = newKeyPassphrase()
+		AssertError(err)
+	}
+
+	AssertError(os.MkdirAll(keyDir(), 0700))
+
+	for _, id := range flags.Args() {
+		writeKeyPair(id, filepath.Join(keyDir(), id + ".priv"), publicKeyFile(id), *scheme, passphrase, *force)
+	}
+}
+
+func writeKeyPair(id string, privKeyFile string, pubKeyFile string, scheme string, passphrase []byte, force bool) {
+	if !force {
+		for _, path := range []string{privKeyFile, pubKeyFile} {
+			if _, err := os.Stat(path); err == nil {
+				AssertError(fmt.Errorf("%s exists; use -force to overwrite", path))
+			}
+		}
+	}
+
+	privKey, err := consensus.GenerateKey(scheme)
+	AssertError(err)
+
+	privPEM, err := consensus.MarshalPrivateKeyPEM(privKey, passphrase)
+	AssertError(err)
+	pubPEM, err := consensus.MarshalPublicKeyPEM(privKey.Public())
+	AssertError(err)
+
+	AssertError(os.WriteFile(privKeyFile, privPEM, 0600))
+	AssertError(os.WriteFile(pubKeyFile, pubPEM, 0644))
+
+	fmt.Printf("%s: %s key pair written to %s, %s\n", id, scheme, privKeyFile, pubKeyFile)
+}
+
+// nodelist [-o node.list] [-grpc-offset n] <ID>=<host>:<port>...
+// nodelist [-o node.list] [-grpc-offset n] -n 4 [-host h] [-port p] [-prefix Node]
+func nodelist(args []string) {
+	flags := flag.NewFlagSet("nodelist", flag.ExitOnError)
+	output := flags.String("o", "node.list", "output file, or - for stdout")
+	grpcOffset := flags.Int("grpc-offset", 0, "if not 0, gRPC port of each node is its port plus this offset")
+	n := flags.Int("n", 0, "number of nodes named <prefix>1 to <prefix>n, if no entries are given")
+	host := flags.String("host", "localhost", "host of the numbered nodes")
+	port := flags.Int("port", 1112, "port of the first numbered node")
+	prefix := flags.String("prefix", "Node", "name prefix of the numbered nodes")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s nodelist [options] <ID>=<host>:<port>...\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "       %s nodelist [options] -n <number of nodes>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var nodeTable []*network.NodeInfo
+	for _, entry := range flags.Args() {
+		idx := strings.IndexByte(entry, '=')
+		if idx <= 0 {
+			AssertError(fmt.Errorf("entry %q is not <ID>=<host>:<port>", entry))
+		}
+		nodeTable = append(nodeTable, &network.NodeInfo{NodeID: entry[:idx], Url: entry[idx+1:]})
+	}
+	for i := 1; len(flags.Args()) == 0 && i <= *n; i++ {
+		nodeTable = append(nodeTable, &network.NodeInfo{
+			NodeID: fmt.Sprintf("%s%d", *prefix, i),
+			Url:    net.JoinHostPort(*host, strconv.Itoa(*port + i - 1)),
+		})
+	}
+	if len(nodeTable) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	for _, nodeInfo := range nodeTable {
+		if *grpcOffset == 0 {
+			continue
+		}
+
+		h, p, err := net.SplitHostPort(nodeInfo.Url)
+		AssertError(err)
+		portNum, err := strconv.Atoi(p)
+		AssertError(err)
+		nodeInfo.GrpcUrl = net.JoinHostPort(h, strconv.Itoa(portNum + *grpcOffset))
+	}
+
+	AssertError(errors.Join(checkNodeTable(nodeTable)...))
+
+	data, err := json.MarshalIndent(nodeTable, "", "\t")
+	AssertError(err)
+	data = append(data, '\n')
+
+	if *output == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+	AssertError(os.WriteFile(*output, data, 0644))
+	fmt.Printf("%d nodes written to %s\n", len(nodeTable), *output)
+}
+
+// validate [node.list [client.list]]
+func validate(args []string) {
+	nodeListFile := "node.list"
+	if len(args) > 0 {
+		nodeListFile = args[0]
+	}
+	nodeTable := loadNodeTable(nodeListFile)
+
+	var clientTable []*network.ClientInfo
+	if len(args) > 1 {
+		clientTable = loadClientTable(args[1])
+	}
+
+	errs := checkNodeTable(nodeTable)
+
+	ids := make([]string, 0)
+	for _, nodeInfo := range nodeTable {
+		ids = append(ids, nodeInfo.NodeID)
+	}
+	for _, clientInfo := range clientTable {
+		if findNodeInfo(nodeTable, clientInfo.ClientID) != nil {
+			errs = append(errs, fmt.Errorf("client %s has the ID of a node", clientInfo.ClientID))
+		}
+		ids = append(ids, clientInfo.ClientID)
+	}
+
+	for _, id := range ids {
+		if err := checkKeyPair(id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(errs) > 0 {
+		fmt.Printf("%d problems found\n", len(errs))
+		os.Exit(1)
+	}
+	fmt.Printf("%d nodes and %d clients have valid keys\n", len(nodeTable), len(clientTable))
+}
+
+// Node IDs and addresses must be unique.
+func checkNodeTable(nodeTable []*network.NodeInfo) []error {
+	var errs []error
+	seen := make(map[string]string)
+
+	for _, nodeInfo := range nodeTable {
+		if nodeInfo.NodeID == "" {
+			errs = append(errs, fmt.Errorf("node at %s has no ID", nodeInfo.Url))
+			continue
+		}
+		if _, _, err := net.SplitHostPort(nodeInfo.Url); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %v", nodeInfo.NodeID, err))
+		}
+
+		keys := []string{"ID " + nodeInfo.NodeID, "address " + nodeInfo.Url}
+		if nodeInfo.GrpcUrl != "" {
+			keys = append(keys, "address " + nodeInfo.GrpcUrl)
+		}
+		for _, key := range keys {
+			if other, ok := seen[key]; ok {
+				errs = append(errs, fmt.Errorf("nodes %s and %s have the same %s", other, nodeInfo.NodeID, key))
+			}
+			seen[key] = nodeInfo.NodeID
+		}
+	}
+
+	return errs
+}
+
+// The public key must be valid. If the private key is present, it
+// must match the public key.
+func checkKeyPair(id string) error {
+	verifier, err := consensus.LoadPublicKey(publicKeyFile(id))
+	if err != nil {
+		return fmt.Errorf("%s: %v", id, err)
+	}
+
+	privKeyFile := filepath.Join(keyDir(), id + ".priv")
+	if _, err := os.Stat(privKeyFile); os.IsNotExist(err) {
+		return nil
+	}
+
+	signer, err := consensus.LoadPrivateKey(privKeyFile, keyPassphrase)
+	if err != nil {
+		return fmt.Errorf("%s: %v", id, err)
+	}
+
+	return checkSigner(id, signer, verifier)
+}
+
+// The private key must match the public key.
+func checkSigner(id string, signer consensus.Signer, verifier consensus.Verifier) error {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return err
+	}
+	signature, err := signer.Sign(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", id, err)
+	}
+	if !verifier.Verify(signature, data) {
+		return fmt.Errorf("%s: private key does not match the public key", id)
+	}
+
+	return nil
+}
+
+// genesis init [options] [node.list]
+// genesis sign [-i genesis.json] <nodeID>...
+// genesis verify [genesis.json]
+func genesisCommand(args []string) {
+	usage := func() {
+		fmt.Printf("Usage: %s genesis init [options] [node.list]\n", os.Args[0])
+		fmt.Printf("       %s genesis sign [-i genesis.json] <nodeID>...\n", os.Args[0])
+		fmt.Printf("       %s genesis verify [genesis.json]\n", os.Args[0])
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+
+	switch args[0] {
+	case "init":
+		genesisInit(args[1:])
+	case "sign":
+		genesisSign(args[1:])
+	case "verify":
+		genesisVerify(args[1:])
+	default:
+		usage()
+	}
+}
+
+// Create the unsigned genesis document of the nodes in the node list,
+// with their public keys from the key directory.
+func genesisInit(args []string) {
+	flags := flag.NewFlagSet("genesis init", flag.ExitOnError)
+	output := flags.String("o", "genesis.json", "output file")
+	clusterID := flags.String("cluster", network.DefaultClusterID, "cluster ID")
+	initialView := flags.Int64("view", 0, "initial view")
+	checkPointPeriod := flags.Int64("checkpoint-period", network.DefaultParams.CheckPointPeriod, "requests between checkpoints")
+	deadline := flags.Int64("deadline-ms", network.DefaultParams.ConsensusDeadlineMs, "consensus deadline in milliseconds")
+	keyGracePeriod := flags.Int64("key-grace-period", network.DefaultParams.KeyGracePeriod, "requests between a key rotation and the switch to the new key")
+	requestTimeout := flags.Int64("request-timeout-ms", network.DefaultParams.RequestTimeoutMs, "time for a request to be executed before backups suspect the primary, in milliseconds")
+	maxPending := flags.Int("max-pending", network.DefaultParams.MaxPendingRequests, "requests a replica keeps until they are executed")
+	clientRateLimit := flags.Float64("client-rate-limit", 0, "requests per second admitted from each client, or 0 for no limit")
+	rateLimit := flags.Float64("rate-limit", 0, "requests per second admitted from all clients, or 0 for no limit")
+	fairnessThreshold := flags.Int64("fairness-threshold-ms", network.DefaultParams.FairnessThresholdMs, "time a request may wait while later requests are ordered, in milliseconds")
+	fairnessViewChange := flags.Bool("fairness-view-change", false, "vote for a view change when a request waits longer than the fairness threshold")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s genesis init [options] [node.list]\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Public keys are read from %s.\n", keyDir())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	nodeListFile := "node.list"
+	if flags.NArg() > 0 {
+		nodeListFile = flags.Arg(0)
+	}
+	nodeTable := loadNodeTable(nodeListFile)
+	AssertError(errors.Join(checkNodeTable(nodeTable)...))
+
+	members := make([]*network.GenesisMember, 0, len(nodeTable))
+	for _, nodeInfo := range nodeTable {
+		// Fail early on a bad key rather than in verify.
+		_, err := consensus.LoadPublicKey(publicKeyFile(nodeInfo.NodeID))
+		AssertError(err)
+		pubKey, err := os.ReadFile(publicKeyFile(nodeInfo.NodeID))
+		AssertError(err)
+
+		members = append(members, &network.GenesisMember{
+			NodeID:  nodeInfo.NodeID,
+			Url:     nodeInfo.Url,
+			GrpcUrl: nodeInfo.GrpcUrl,
+			PubKey:  string(pubKey),
+		})
+	}
+
+	params := network.Params{
+		CheckPointPeriod:    *checkPointPeriod,
+		ConsensusDeadlineMs: *deadline,
+		KeyGracePeriod:      *keyGracePeriod,
+		RequestTimeoutMs:    *requestTimeout,
+		MaxPendingRequests:  *maxPending,
+		ClientRateLimit:     *clientRateLimit,
+		RateLimit:           *rateLimit,
+		FairnessThresholdMs: *fairnessThreshold,
+		FairnessViewChange:  *fairnessViewChange,
+	}
+	genesis := network.NewGenesis(*clusterID, *initialView, params, members)
+
+	AssertError(genesis.Save(*output))
+	fmt.Printf("Genesis %s of %d members written to %s; every member must sign it\n",
+	           genesis.Digest(), len(members), *output)
+}
+
+// Add the signatures of the nodes, with their private keys.
+func genesisSign(args []string) {
+	flags := flag.NewFlagSet("genesis sign", flag.ExitOnError)
+	input := flags.String("i", "genesis.json", "genesis file, signed in place")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Fprintf(flags.Output(), "Usage: %s genesis sign [-i genesis.json] <nodeID>...\n", os.Args[0])
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+
+	genesis, err := network.LoadGenesis(*input)
+	AssertError(err)
+
+	for _, nodeID := range flags.Args() {
+		signer, err := loadPrivateKey(nodeID)
+		AssertError(err)
+		AssertError(genesis.Sign(nodeID, signer))
+
+		fmt.Printf("%s signed genesis %s\n", nodeID, genesis.Digest())
+	}
+
+	AssertError(genesis.Save(*input))
+}
+
+func genesisVerify(args []string) {
+	genesisFile := "genesis.json"
+	if len(args) > 0 {
+		genesisFile = args[0]
+	}
+
+	genesis, err := network.LoadGenesis(genesisFile)
+	AssertError(err)
+
+	nodeTable, err := genesis.Verify()
+	if err != nil {
+		fmt.Printf("genesis %s: %v\n", genesisFile, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Genesis %s verified: cluster %s, %d members, f=%d, initial view %d, params %+v\n",
+	           genesis.Digest(), genesis.ClusterID, len(nodeTable), genesis.F,
+	           genesis.InitialView, genesis.Params)
+}
+
+// rotatekey [-scheme p224|p256|ed25519] [-encrypt] [-force] <nodeID> [genesis.json|node.list]
+func rotatekey(args []string) {
+	flags := flag.NewFlagSet("rotatekey", flag.ExitOnError)
+	scheme := flags.String("scheme", "p224", fmt.Sprintf("key scheme, one of %v", consensus.KeySchemes))
+	encrypt := flags.Bool("encrypt", false, "encrypt the private key with a passphrase (PBFT_KEY_PASSPHRASE or prompted)")
+	force := flags.Bool("force", false, "overwrite an existing next key")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s rotatekey [options] <nodeID> [genesis.json|node.list]\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "The next key pair is written to %s as <nodeID>.next.priv and <nodeID>.next.pub.\n", keyDir())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	nodeID := flags.Arg(0)
+	configFile := "node.list"
+	if flags.NArg() > 1 {
+		configFile = flags.Arg(1)
+	}
+
+	nodeTable, genesis := loadClusterConfig(configFile)
+	nodeInfo := findNodeInfo(nodeTable, nodeID)
+	if nodeInfo == nil {
+		AssertError(fmt.Errorf("node %s is not in %s", nodeID, configFile))
+	}
+	clusterID := os.Getenv("PBFT_CLUSTER_ID")
+	if genesis != nil {
+		clusterID = genesis.ClusterID
+	}
+
+	// The request is signed with the current key of the node.
+	signer, err := loadPrivateKey(nodeID)
+	AssertError(err)
+
+	var passphrase []byte
+	if *encrypt {
+		var err error
+		passphrase, err = newKeyPassphrase()
+		AssertError(err)
+	}
+	writeKeyPair(nodeID, nextPrivateKeyFile(nodeID), nextPublicKeyFile(nodeID), *scheme, passphrase, *force)
+
+	// The node itself signs the rotation with its current and its
+	// next key, and orders it through consensus.
+	client, httpScheme := newReplicaHTTPClient(nodeTable)
+	u := url.URL{Scheme: httpScheme, Host: nodeInfo.Url, Path: "/rotatekey"}
+	nonce, err := network.FetchNonce(client, httpScheme + "://" + nodeInfo.Url)
+	AssertError(err)
+	header, err := network.RotateKeyHeader(clusterID, nodeID, nonce, signer)
+	AssertError(err)
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), nil)
+	AssertError(err)
+	req.Header = header
+	resp, err := client.Do(req)
+	AssertError(err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	AssertError(err)
+	if resp.StatusCode != http.StatusOK {
+		AssertError(fmt.Errorf("%s: %s: %s", nodeID, resp.Status, strings.TrimSpace(string(body))))
+	}
+
+	fmt.Printf("Key rotation of %s requested; the node switches keys once it is executed.\n", nodeID)
+	fmt.Printf("Then replace %s with %s, and distribute %s.\n",
+	           privateKeyFile(nodeID), nextPrivateKeyFile(nodeID), nextPublicKeyFile(nodeID))
+}
+
+func findNodeInfo(nodeTable []*network.NodeInfo, nodeID string) *network.NodeInfo {
+	for _, nodeInfo := range nodeTable {
+		if nodeInfo.NodeID == nodeID {
+			return nodeInfo
+		}
+	}
+
+	return nil
+}
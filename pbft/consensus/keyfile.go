@@ -0,0 +1,333 @@
+// Key files. Public keys are PEM encoded PKIX keys. Private keys are
+// PEM encoded SEC 1 or PKCS #8 keys, and may be encrypted with a
+// passphrase as PKCS #8 with PBES2 ("ENCRYPTED PRIVATE KEY"), e.g.,
+//   openssl pkcs8 -topk8 -v2 aes-256-cbc -in Node1.priv -out Node1.enc
+
+package consensus
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+)
+
+// Passphrase returns the passphrase of the encrypted private key file.
+type Passphrase func(path string) ([]byte, error)
+
+// LoadPublicKey reads a public key file. Errors name the file.
+func LoadPublicKey(path string) (Verifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := ParsePublicKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("public key %s: %v", path, err)
+	}
+
+	return verifier, nil
+}
+
+// LoadPrivateKey reads a private key file. The passphrase is asked
+// only if the key is encrypted. Errors name the file.
+func LoadPrivateKey(path string, passphrase Passphrase) (Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("private key %s: no PEM block found", path)
+	}
+
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		if passphrase == nil {
+			return nil, fmt.Errorf("private key %s is encrypted, but no passphrase is given", path)
+		}
+		pass, err := passphrase(path)
+		if err != nil {
+			return nil, fmt.Errorf("passphrase for %s: %v", path, err)
+		}
+
+		der, err := decryptPKCS8(block.Bytes, pass)
+		if err != nil {
+			return nil, fmt.Errorf("private key %s: %v", path, err)
+		}
+		data = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	case block.Headers["Proc-Type"] != "":
+		return nil, fmt.Errorf("private key %s: legacy PEM encryption is not supported; "+
+		                       "convert it with 'openssl pkcs8 -topk8 -v2 aes-256-cbc'", path)
+	}
+
+	signer, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("private key %s: %v", path, err)
+	}
+
+	return signer, nil
+}
+
+// Key schemes for GenerateKey.
+var KeySchemes = []string{"p224", "p256", "ed25519"}
+
+// GenerateKey generates a private key of the given scheme.
+func GenerateKey(scheme string) (crypto.Signer, error) {
+	switch scheme {
+	case "p224":
+		return ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	case "p256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ed25519":
+		_, privKey, err := ed25519.GenerateKey(rand.Reader)
+		return privKey, err
+	}
+
+	return nil, fmt.Errorf("unknown key scheme %q (one of %v)", scheme, KeySchemes)
+}
+
+// MarshalPrivateKeyPEM encodes a private key as PKCS #8, encrypted if
+// the passphrase is not empty.
+func MarshalPrivateKeyPEM(privKey crypto.PrivateKey, passphrase []byte) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passphrase) == 0 {
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	}
+
+	der, err = encryptPKCS8(der, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}), nil
+}
+
+// MarshalPublicKeyPEM encodes a public key as PKIX.
+func MarshalPublicKeyPEM(pubKey crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// MarshalVerifierPEM encodes the public key of a verifier created by
+// NewVerifier, e.g., that of a signer, as PKIX.
+func MarshalVerifierPEM(verifier Verifier) ([]byte, error) {
+	switch v := verifier.(type) {
+	case *ecdsaVerifier:
+		return MarshalPublicKeyPEM(v.key)
+	case ed25519Verifier:
+		return MarshalPublicKeyPEM(ed25519.PublicKey(v))
+	}
+
+	return nil, fmt.Errorf("unsupported verifier %T", verifier)
+}
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// RFC 5208 and RFC 8018.
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// Iterations of PBKDF2 for keys encrypted by encryptPKCS8.
+const pbkdf2Iterations = 100000
+
+// Most iterations of PBKDF2 for keys read from files, so a crafted
+// key file cannot keep the node from starting.
+const maxPBKDF2Iterations = 10000000
+
+// Encrypt a PKCS #8 PrivateKeyInfo with PBES2, using PBKDF2 with
+// HMAC-SHA256 and AES-256-CBC.
+func encryptPKCS8(der []byte, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS #7 padding.
+	pad := aes.BlockSize - len(der) % aes.BlockSize
+	plain := append(append([]byte{}, der...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, plain)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pbkdf2Iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBKDF2,
+			Parameters: asn1.RawValue{FullBytes: kdfParams},
+		},
+		EncryptionScheme: pkix.AlgorithmIdentifier{
+			Algorithm:  oidAES256CBC,
+			Parameters: asn1.RawValue{FullBytes: ivParam},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+}
+
+// Decrypt a PKCS #8 EncryptedPrivateKeyInfo into a PrivateKeyInfo.
+func decryptPKCS8(der []byte, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported encryption %v, only PBES2 is supported", info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation %v", params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, err
+	}
+
+	if kdf.IterationCount < 1 || kdf.IterationCount > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("PBKDF2 iteration count %d is not between 1 and %d", kdf.IterationCount, maxPBKDF2Iterations)
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdf.PRF.Algorithm) == 0, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 function %v", kdf.PRF.Algorithm)
+	}
+
+	var keyLen int
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLen = 16
+	case scheme.Equal(oidAES192CBC):
+		keyLen = 24
+	case scheme.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported cipher %v", scheme)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize || len(info.EncryptedData) == 0 ||
+	   len(info.EncryptedData) % aes.BlockSize != 0 {
+		return nil, errors.New("malformed encrypted key")
+	}
+
+	key, err := pbkdf2.Key(prf, string(passphrase), kdf.Salt, kdf.IterationCount, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plain := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, info.EncryptedData)
+
+	// Remove PKCS #7 padding; bad padding almost always means a
+	// wrong passphrase.
+	errWrongPassphrase := errors.New("wrong passphrase or corrupted key")
+	pad := int(plain[len(plain) - 1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, errWrongPassphrase
+	}
+	for _, b := range plain[len(plain) - pad:] {
+		if int(b) != pad {
+			return nil, errWrongPassphrase
+		}
+	}
+	plain = plain[:len(plain) - pad]
+
+	if _, err := x509.ParsePKCS8PrivateKey(plain); err != nil {
+		return nil, errWrongPassphrase
+	}
+
+	return plain, nil
+}
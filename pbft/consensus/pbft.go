@@ -1,5 +1,9 @@
 package consensus
 
+import (
+	"fmt"
+)
+
 type PBFT interface {
 	StartConsensus(request *RequestMsg, sequenceID int64) *PrePrepareMsg
 	PrePrepare(prePrepareMsg *PrePrepareMsg) (*VoteMsg, error)
@@ -9,6 +13,7 @@ type PBFT interface {
 	GetSequenceID() int64
 	GetDigest() string
 	GetF() int
+	GetStage() Stage
 
 	GetMsgReceiveChannel() <-chan interface{}
 	GetMsgSendChannel() chan<- interface{}
@@ -29,3 +34,27 @@ type PBFT interface {
 	ClearMsgLogs()
 	Redo_SetState(viewID int64, nodeID string, totNodes int, preprepareMsg *PrePrepareMsg, digest string) *State
 }
+
+// Stage of the consensus on a request reached by this node.
+type Stage int
+const (
+	Idle        Stage = iota // no PRE-PREPARE message yet
+	PrePrepared              // PRE-PREPARE message accepted
+	Prepared                 // prepared certificate collected (see State.prepared)
+	Committed                // committed certificate collected (see State.committed)
+)
+
+func (stage Stage) String() string {
+	switch stage {
+	case Idle:
+		return "idle"
+	case PrePrepared:
+		return "pre-prepared"
+	case Prepared:
+		return "prepared"
+	case Committed:
+		return "committed"
+	}
+
+	return fmt.Sprintf("Stage(%d)", int(stage))
+}
@@ -94,13 +94,14 @@ func (state *State) StartConsensus(request *RequestMsg, sequenceID int64) *PrePr
 	state.MsgLogs.ReqMsg = request
 
 	// Get the digest of the request message
-	state.digest = Digest(request)
+	state.digest = request.Digest()
 
 	// Create PREPREPARE message.
 	prePrepareMsg := &PrePrepareMsg{
 		ViewID:     state.ViewID,
 		SequenceID: request.SequenceID,
 		Digest:     state.digest,
+		RequestMsg: request,
 	}
 
 	// Accessing to the message log without locking is safe because
@@ -139,7 +140,8 @@ func (state *State) Prepare(prepareMsg *VoteMsg) (*VoteMsg, error) {
 		return nil, errors.New("prepare message is corrupted: " + err.Error() + " (nodeID: " + prepareMsg.NodeID + ")")
 	}
 
-	// Append msg to its logs
+	// Append msg to its logs. Votes are counted per sender; the
+	// transport rejects messages whose NodeID is not the signer.
 	state.MsgLogs.PrepareMsgsMutex.Lock()
 	if _, ok := state.MsgLogs.PrepareMsgs[prepareMsg.NodeID]; ok {
 		fmt.Printf("Prepare message from %s is already received, sequence number=%d\n",
@@ -177,7 +179,8 @@ func (state *State) Commit(commitMsg *VoteMsg) (*ReplyMsg, *RequestMsg, error) {
 		return nil, nil, errors.New("commit message is corrupted: " + err.Error() + " (nodeID: " + commitMsg.NodeID + ")")
 	}
 
-	// Append msg to its logs
+	// Append msg to its logs. Votes are counted per sender; the
+	// transport rejects messages whose NodeID is not the signer.
 	state.MsgLogs.CommitMsgsMutex.Lock()
 	if _, ok := state.MsgLogs.CommitMsgs[commitMsg.NodeID]; ok {
 		fmt.Printf("Commit message from %s is already received, sequence number=%d\n",
@@ -220,6 +223,20 @@ func (state *State) GetSequenceID() int64 {
 	return state.SequenceID
 }
 
+// Stage from the certificates collected so far.
+func (state *State) GetStage() Stage {
+	switch {
+	case state.committed():
+		return Committed
+	case state.prepared():
+		return Prepared
+	case state.MsgLogs.PrePrepareMsg != nil:
+		return PrePrepared
+	}
+
+	return Idle
+}
+
 func (state *State) GetDigest() string {
 	return state.digest
 }
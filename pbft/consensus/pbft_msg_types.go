@@ -1,9 +1,5 @@
 package consensus
 
-import (
-	"math/big"
-)
-
 // Messages are TOCS style.
 
 type RequestMsg struct {
@@ -12,6 +8,7 @@ type RequestMsg struct {
 	Operation  string `json:"operation"`
 	Data       string `json:"data"`
 	SequenceID int64  `json:"sequenceID"`
+	Signature  []byte `json:"signature,omitempty"` // by the client (see RequestMsg.Sign)
 }
 
 type ReplyMsg struct {
@@ -22,10 +19,16 @@ type ReplyMsg struct {
 	Result    string `json:"result"`
 }
 
+// From TOCS: The request message is piggybacked on the PRE-PREPARE
+// message, and the digest in it is that of the request. The backups
+// order the copy of the primary rather than the one relayed to them,
+// so a client cannot make replicas disagree on the request by sending
+// them different copies of it.
 type PrePrepareMsg struct {
 	ViewID     int64       `json:"viewID"`
 	SequenceID int64       `json:"sequenceID"`
 	Digest     string      `json:"digest"`
+	RequestMsg *RequestMsg `json:"requestMsg,omitempty"`
 }
 
 type VoteMsg struct {
@@ -72,12 +75,22 @@ type NewViewMsg struct {
 	Min_S int64 `json:"min_s"`
 }
 
+// Version of the signed message envelope. Messages of other versions
+// are rejected.
+const ProtocolVersion = 1
+
+// Signed envelope of a consensus message. The signature covers all
+// the other fields (see SigningBytes), so a message cannot be replayed
+// as another message type or into another cluster.
 type SignatureMsg struct {
-	// signature
-	Signature []byte `json:"signature"`
-	R *big.Int `json:"r"`
-	S *big.Int `json:"s"`
+	Version   uint32 `json:"version"`
+	ClusterID string `json:"clusterID"`
+	MsgType   string `json:"msgType"` // e.g., "/prepare"
+	NodeID    string `json:"nodeID"`  // node which signed the message
 
 	// any consensus messages
 	MarshalledMsg []byte `json:"marshalledmsg"`
+
+	// in the scheme of the signer (see signer.go)
+	Signature []byte `json:"signature"`
 }
@@ -50,12 +50,14 @@ func CreateViewChangeState(nodeID string, totNodes int, nextviewID int64, stable
 
 func (vcs *VCState) ViewChange(viewchangeMsg *ViewChangeMsg) (*NewViewMsg, error) {
 	// verify VIEW-CHANGE message.
-	// TODO verity sender's signature
+	// The signature of the sender is verified by the transport.
 	//if err := vcs.verifyVCMsg(viewchangeMsg.NodeID, viewchangeMsg.NextViewID, viewchangeMsg.StableCheckPoint); err != nil {
 	//	return nil, errors.New("view-change message is corrupted: " + err.Error() + " (nextviewID " + fmt.Sprintf("%d", viewchangeMsg.NextViewID) + ")")
 	//}
 
-	// Append VIEW-CHANGE message to its logs.
+	// Append VIEW-CHANGE message to its logs. Messages are counted
+	// per sender; the transport rejects messages whose NodeID is not
+	// the signer.
 	vcs.ViewChangeMsgLogs.ViewChangeMsgMutex.Lock()
         if _, ok := vcs.ViewChangeMsgLogs.ViewChangeMsgs[viewchangeMsg.NodeID]; ok {
                 fmt.Printf("View-change message from %s is already received, next view number=%d\n",
@@ -0,0 +1,178 @@
+// Signature schemes. Each node and client has its own key type, which
+// is detected from its PEM file, so faster schemes can be chosen and
+// mixed during a key migration.
+//
+// Supported schemes:
+//   ECDSA with SHA-256 over P-224 or P-256. Signatures are r and s
+//   concatenated, each padded to the byte size of the curve order.
+//   Ed25519.
+
+package consensus
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Signer signs messages with a private key.
+type Signer interface {
+	Sign(data []byte) ([]byte, error)
+
+	// Verifier for the corresponding public key.
+	Verifier() Verifier
+}
+
+// Verifier verifies signatures with a public key.
+type Verifier interface {
+	Verify(signature []byte, data []byte) bool
+}
+
+// NewSigner returns the signer for an ECDSA (P-224, P-256) or Ed25519
+// private key.
+func NewSigner(privKey crypto.PrivateKey) (Signer, error) {
+	switch key := privKey.(type) {
+	case *ecdsa.PrivateKey:
+		if err := checkCurve(key.Curve); err != nil {
+			return nil, err
+		}
+		return &ecdsaSigner{key}, nil
+	case ed25519.PrivateKey:
+		return ed25519Signer(key), nil
+	}
+
+	return nil, fmt.Errorf("unsupported private key type %T", privKey)
+}
+
+// NewVerifier returns the verifier for an ECDSA (P-224, P-256) or
+// Ed25519 public key.
+func NewVerifier(pubKey crypto.PublicKey) (Verifier, error) {
+	switch key := pubKey.(type) {
+	case *ecdsa.PublicKey:
+		if err := checkCurve(key.Curve); err != nil {
+			return nil, err
+		}
+		return &ecdsaVerifier{key}, nil
+	case ed25519.PublicKey:
+		return ed25519Verifier(key), nil
+	}
+
+	return nil, fmt.Errorf("unsupported public key type %T", pubKey)
+}
+
+// ParsePrivateKeyPEM parses a PEM encoded private key: SEC 1 ("EC
+// PRIVATE KEY") for ECDSA, or PKCS #8 ("PRIVATE KEY") for any scheme.
+func ParsePrivateKeyPEM(data []byte) (Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var privKey crypto.PrivateKey
+	var err error
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		privKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigner(privKey)
+}
+
+// ParsePublicKeyPEM parses a PEM encoded PKIX ("PUBLIC KEY") public key.
+func ParsePublicKeyPEM(data []byte) (Verifier, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+
+	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewVerifier(pubKey)
+}
+
+func checkCurve(curve elliptic.Curve) error {
+	if curve != elliptic.P224() && curve != elliptic.P256() {
+		return fmt.Errorf("unsupported ECDSA curve %s", curve.Params().Name)
+	}
+
+	return nil
+}
+
+type ecdsaSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s *ecdsaSigner) Sign(data []byte) ([]byte, error) {
+	signHash := sha256.Sum256(data)
+
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, signHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	size := (s.key.Curve.Params().N.BitLen() + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	sig.FillBytes(signature[size:])
+
+	return signature, nil
+}
+
+func (s *ecdsaSigner) Verifier() Verifier {
+	return &ecdsaVerifier{&s.key.PublicKey}
+}
+
+type ecdsaVerifier struct {
+	key *ecdsa.PublicKey
+}
+
+func (v *ecdsaVerifier) Verify(signature []byte, data []byte) bool {
+	size := (v.key.Curve.Params().N.BitLen() + 7) / 8
+	if len(signature) != 2*size {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+	signHash := sha256.Sum256(data)
+
+	return ecdsa.Verify(v.key, signHash[:], r, s)
+}
+
+type ed25519Signer ed25519.PrivateKey
+
+func (s ed25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(s), data), nil
+}
+
+func (s ed25519Signer) Verifier() Verifier {
+	return ed25519Verifier(ed25519.PrivateKey(s).Public().(ed25519.PublicKey))
+}
+
+type ed25519Verifier ed25519.PublicKey
+
+func (v ed25519Verifier) Verify(signature []byte, data []byte) bool {
+	return len(signature) == ed25519.SignatureSize &&
+	       ed25519.Verify(ed25519.PublicKey(v), data, signature)
+}
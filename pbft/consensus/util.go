@@ -1,12 +1,11 @@
 package consensus
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
-	"math/big"
-	"crypto/ecdsa"
-	"crypto/rand"
 )
 
 func Hash(content []byte) string {
@@ -25,24 +24,85 @@ func Digest(object interface{}) string {
 	return Hash(msg)
 }
 
-func Sign(privKey *ecdsa.PrivateKey, data []byte) (*big.Int, *big.Int, []byte, error) {
-	r := big.NewInt(0)
-	s := big.NewInt(0)
-	signHash := sha256.Sum256(data)
+// Prefix of all signed data, so that signatures on messages cannot be
+// used for anything else signed with the same key.
+const signingDomain = "consensusPBFT signed message"
 
-	r, s, err := ecdsa.Sign(rand.Reader, privKey, signHash[:])
+// SigningBytes encodes the signed part of a message envelope. Variable
+// length fields are length-prefixed, so the encoding is unambiguous.
+func SigningBytes(version uint32, clusterID string, msgType string, sender string, payload []byte) []byte {
+	var buf bytes.Buffer
 
+	writeField := func(field []byte) {
+		binary.Write(&buf, binary.BigEndian, uint32(len(field)))
+		buf.Write(field)
+	}
+
+	writeField([]byte(signingDomain))
+	binary.Write(&buf, binary.BigEndian, version)
+	writeField([]byte(clusterID))
+	writeField([]byte(msgType))
+	writeField([]byte(sender))
+	writeField(payload)
+
+	return buf.Bytes()
+}
+
+func (msg *SignatureMsg) signingBytes() []byte {
+	return SigningBytes(msg.Version, msg.ClusterID, msg.MsgType, msg.NodeID, msg.MarshalledMsg)
+}
+
+// Sign fills the signature of the envelope.
+func (msg *SignatureMsg) Sign(signer Signer) error {
+	signature, err := signer.Sign(msg.signingBytes())
 	if err != nil {
-		return nil, nil, nil, err
+		return err
 	}
+	msg.Signature = signature
 
-	signature := r.Bytes()
-	signature = append(signature, s.Bytes()...)
+	return nil
+}
 
-	return r, s, signature, nil
+// Verify checks the signature of the envelope.
+func (msg *SignatureMsg) Verify(verifier Verifier) bool {
+	return verifier.Verify(msg.Signature, msg.signingBytes())
 }
 
-func Verify(pubKey *ecdsa.PublicKey, r, s *big.Int, data []byte) bool {
-	signHash := sha256.Sum256(data)
-	return ecdsa.Verify(pubKey, signHash[:], r, s)
+// Signed part of a request. The sequence number is assigned by the
+// primary, so it is not signed by the client.
+func (msg *RequestMsg) signingBytes(clusterID string) []byte {
+	payload, _ := json.Marshal(&RequestMsg{
+		Timestamp: msg.Timestamp,
+		ClientID:  msg.ClientID,
+		Operation: msg.Operation,
+		Data:      msg.Data,
+	})
+
+	return SigningBytes(ProtocolVersion, clusterID, "/req", msg.ClientID, payload)
+}
+
+// Sign fills the signature of the client on the request.
+func (msg *RequestMsg) Sign(clusterID string, signer Signer) error {
+	signature, err := signer.Sign(msg.signingBytes(clusterID))
+	if err != nil {
+		return err
+	}
+	msg.Signature = signature
+
+	return nil
+}
+
+// Verify checks the signature of the client on the request.
+func (msg *RequestMsg) Verify(clusterID string, verifier Verifier) bool {
+	return verifier.Verify(msg.Signature, msg.signingBytes(clusterID))
+}
+
+// Digest of the request to be ordered. The signature of the client is
+// left out, as it only authenticates the request: a client signing the
+// request again (e.g., with a randomized scheme) sends the same request.
+func (msg *RequestMsg) Digest() string {
+	unsigned := *msg
+	unsigned.Signature = nil
+
+	return Digest(&unsigned)
 }
@@ -5,13 +5,16 @@ NC='\033[0m'
 
 if [[ $# -lt 1 ]]
 then
-	echo "Usage: $0 <number of nodes>"
-	echo "Example: $0 100"
+	echo "Usage: $0 <number of nodes> [number of clients]"
+	echo "Example: $0 100 10"
+	echo "KEYTYPE selects the scheme: secp224r1 (default), prime256v1 or ed25519"
 
 	exit
 fi
 
 NUMNODES=$1
+NUMCLIENTS=${2:-0}
+KEYTYPE=${KEYTYPE:-secp224r1}
 KEYPATH="keys"
 
 # Remove existing keys.
@@ -26,20 +29,43 @@ then
         exit
 fi
 
-for i in `seq 1 $NUMNODES`
+NAMES=""
+for i in `seq 1 $NUMNODES`; do NAMES="$NAMES Node$i"; done
+for i in `seq 1 $NUMCLIENTS`; do NAMES="$NAMES Client$i"; done
+
+for name in $NAMES
 do
-	PRIVKEYFILE="$KEYPATH/Node$i.priv"
-	PUBKEYFILE="$KEYPATH/Node$i.pub"
+	PRIVKEYFILE="$KEYPATH/$name.priv"
+	PUBKEYFILE="$KEYPATH/$name.pub"
 
 	(
-	 # NIST says secp224r1 is as strong as rsa-2048.
-	 # Refer to Table 2-1 from:
-	 # https://dx.doi.org/10.6028/NIST.SP.800-57pt3r1
-	 openssl ecparam -name secp224r1 -genkey -noout -out $PRIVKEYFILE
-	 openssl ec -in $PRIVKEYFILE -pubout -out $PUBKEYFILE
+	 if [[ $KEYTYPE == "ed25519" ]]
+	 then
+		openssl genpkey -algorithm ed25519 -out $PRIVKEYFILE
+		openssl pkey -in $PRIVKEYFILE -pubout -out $PUBKEYFILE
+	 else
+		# NIST says secp224r1 is as strong as rsa-2048.
+		# Refer to Table 2-1 from:
+		# https://dx.doi.org/10.6028/NIST.SP.800-57pt3r1
+		openssl ecparam -name $KEYTYPE -genkey -noout -out $PRIVKEYFILE
+		openssl ec -in $PRIVKEYFILE -pubout -out $PUBKEYFILE
+	 fi
 	) &
 done
 
 wait
 
-printf "${RED}$NUMNODES keys created!${NC}\n"
+# Client list for the nodes.
+if [[ $NUMCLIENTS -gt 0 ]]
+then
+	echo "[" > client.list
+	for i in `seq 1 $NUMCLIENTS`
+	do
+		COMMA=","
+		if [[ $i -eq $NUMCLIENTS ]]; then COMMA=""; fi
+		printf "\t{\"clientID\": \"Client$i\"}$COMMA\n" >> client.list
+	done
+	echo "]" >> client.list
+fi
+
+printf "${RED}$NUMNODES node keys and $NUMCLIENTS client keys created!${NC}\n"
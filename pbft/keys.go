@@ -0,0 +1,133 @@
+// Key files of the nodes and clients.
+//
+// Keys are read from PBFT_KEY_DIR ("keys" by default) as <ID>.pub and
+// <ID>.priv. PBFT_PRIVATE_KEY overrides the private key file of this
+// node. Encrypted private keys are decrypted with PBFT_KEY_PASSPHRASE,
+// or with a passphrase prompted on the terminal.
+//
+// The key a node rotates to is read from <ID>.next.priv when the
+// rotation is requested (see rotatekey). Once rotated, it replaces
+// <ID>.priv, and <ID>.next.pub is distributed as <ID>.pub (or in a new
+// genesis document) before the nodes restart.
+
+package main
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/network"
+	"golang.org/x/term"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func keyDir() string {
+	if dir := os.Getenv("PBFT_KEY_DIR"); dir != "" {
+		return dir
+	}
+
+	return "keys"
+}
+
+func publicKeyFile(id string) string {
+	return filepath.Join(keyDir(), id + ".pub")
+}
+
+func privateKeyFile(id string) string {
+	if path := os.Getenv("PBFT_PRIVATE_KEY"); path != "" {
+		return path
+	}
+
+	return filepath.Join(keyDir(), id + ".priv")
+}
+
+func nextPrivateKeyFile(id string) string {
+	return filepath.Join(keyDir(), id + ".next.priv")
+}
+
+func nextPublicKeyFile(id string) string {
+	return filepath.Join(keyDir(), id + ".next.pub")
+}
+
+func loadPublicKeys(nodeTable []*network.NodeInfo, clientTable []*network.ClientInfo) error {
+	var err error
+
+	for _, nodeInfo := range nodeTable {
+		nodeInfo.PubKey, err = consensus.LoadPublicKey(publicKeyFile(nodeInfo.NodeID))
+		if err != nil {
+			return fmt.Errorf("key of node %s: %v", nodeInfo.NodeID, err)
+		}
+	}
+
+	for _, clientInfo := range clientTable {
+		clientInfo.PubKey, err = consensus.LoadPublicKey(publicKeyFile(clientInfo.ClientID))
+		if err != nil {
+			return fmt.Errorf("key of client %s: %v", clientInfo.ClientID, err)
+		}
+	}
+
+	return nil
+}
+
+func loadPrivateKey(id string) (consensus.Signer, error) {
+	signer, err := consensus.LoadPrivateKey(privateKeyFile(id), keyPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("key of %s: %v", id, err)
+	}
+
+	return signer, nil
+}
+
+func loadNextPrivateKey(id string) (consensus.Signer, error) {
+	signer, err := consensus.LoadPrivateKey(nextPrivateKeyFile(id), keyPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("next key of %s: %v", id, err)
+	}
+
+	return signer, nil
+}
+
+// Passphrase from PBFT_KEY_PASSPHRASE, or else from the terminal.
+func keyPassphrase(path string) ([]byte, error) {
+	if passphrase, ok := os.LookupEnv("PBFT_KEY_PASSPHRASE"); ok {
+		return []byte(passphrase), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("set PBFT_KEY_PASSPHRASE, or run on a terminal to be prompted")
+	}
+
+	fmt.Fprintf(os.Stderr, "Passphrase for %s: ", path)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+
+	return passphrase, err
+}
+
+// Passphrase for new keys from PBFT_KEY_PASSPHRASE, or else prompted
+// twice on the terminal.
+func newKeyPassphrase() ([]byte, error) {
+	if passphrase, ok := os.LookupEnv("PBFT_KEY_PASSPHRASE"); ok {
+		return []byte(passphrase), nil
+	}
+
+	passphrase, err := keyPassphrase("new keys")
+	if err != nil {
+		return nil, err
+	}
+	again, err := keyPassphrase("new keys (again)")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	if string(passphrase) != string(again) {
+		return nil, errors.New("passphrases do not match")
+	}
+
+	return passphrase, nil
+}
@@ -1,14 +1,13 @@
 package main
 
 import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/checker"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
 	"github.com/bigpicturelabs/consensusPBFT/pbft/network"
+	"bytes"
 	"os"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"crypto/ecdsa"
-	"encoding/pem"
-	"crypto/x509"
 	"log"
 )
 
@@ -21,69 +20,225 @@ var nodeTableForTest = []*network.NodeInfo {
 	{NodeID: "IBM",    Url: "localhost:1114"},
 }
 
-func PrivateKeyDecode(pemEncoded []byte) *ecdsa.PrivateKey {
-	blockPriv, _ := pem.Decode(pemEncoded)
-	x509Encoded := blockPriv.Bytes
-	privateKey, _ := x509.ParseECPrivateKey(x509Encoded)
-
-	return privateKey
-}
-
-func PublicKeyDecode(pemEncoded []byte) *ecdsa.PublicKey {
-	blockPub, _ := pem.Decode(pemEncoded)
-	x509EncodedPub := blockPub.Bytes
-	genericPublicKey, _ := x509.ParsePKIXPublicKey(x509EncodedPub)
-	publicKey := genericPublicKey.(*ecdsa.PublicKey)
-
-	return publicKey
-}
-
 func main() {
 	var nodeTable []*network.NodeInfo
 
 	if len(os.Args) < 2 {
-		fmt.Println("Usage:", os.Args[0], "<nodeID> [node.list]")
+		fmt.Println("Usage:", os.Args[0], "<nodeID> [genesis.json|node.list [client.list]]")
+		fmt.Println("      ", os.Args[0], "check [genesis.json|node.list [history.json...]]")
+		fmt.Println("      ", os.Args[0], "keygen [options] <ID>...")
+		fmt.Println("      ", os.Args[0], "nodelist [options] <ID>=<host>:<port>...")
+		fmt.Println("      ", os.Args[0], "validate [node.list [client.list]]")
+		fmt.Println("      ", os.Args[0], "genesis init|sign|verify ...")
+		fmt.Println("      ", os.Args[0], "rotatekey [options] <nodeID> [genesis.json|node.list]")
+		fmt.Println("      ", os.Args[0], "client [options] submit|status|tail ...")
+		fmt.Println("      ", os.Args[0], "bench [options]")
 		return
 	}
 
+	switch os.Args[1] {
+	case "keygen":
+		keygen(os.Args[2:])
+		return
+	case "nodelist":
+		nodelist(os.Args[2:])
+		return
+	case "validate":
+		validate(os.Args[2:])
+		return
+	case "genesis":
+		genesisCommand(os.Args[2:])
+		return
+	case "rotatekey":
+		rotatekey(os.Args[2:])
+		return
+	case "client":
+		clientCommand(os.Args[2:])
+		return
+	case "bench":
+		bench(os.Args[2:])
+		return
+	}
+
+	// The genesis document carries the public keys of the nodes
+	// and the cluster configuration; a bare node list does not.
+	var genesis *network.Genesis
+
 	nodeID := os.Args[1]
 	if len(os.Args) == 2 {
 		fmt.Println("Node list are not specified")
 		fmt.Println("Embedded list is used for test")
 		nodeTable = nodeTableForTest
 	} else {
-		nodeListFile := os.Args[2]
-		jsonFile, err := os.Open(nodeListFile)
-		AssertError(err)
-		defer jsonFile.Close()
-
-		err = json.NewDecoder(jsonFile).Decode(&nodeTable)
-		AssertError(err)
+		nodeTable, genesis = loadClusterConfig(os.Args[2])
 	}
 
-	// Load public key for each node.
-	for _, nodeInfo := range nodeTable {
-		pubKeyFile := fmt.Sprintf("keys/%s.pub", nodeInfo.NodeID)
-		pubBytes, err := ioutil.ReadFile(pubKeyFile)
-		AssertError(err)
+	// PBFT_TLS_DIR enables mutual TLS with the certificates
+	// created by cert_gen.sh.
+	tlsDir := os.Getenv("PBFT_TLS_DIR")
+
+	if nodeID == "check" {
+		if tlsDir != "" {
+			tlsConfig, err := network.LoadClientTLSConfig(tlsDir, nodeTable)
+			AssertError(err)
+			checker.SetTLSConfig(tlsConfig)
+		}
+		var histories []string
+		if len(os.Args) > 3 {
+			histories = os.Args[3:]
+		}
+		check(nodeTable, histories)
+		return
+	}
 
-		decodePubKey := PublicKeyDecode(pubBytes)
-		nodeInfo.PubKey = decodePubKey
+	// Load public key for each node and client. Without a client
+	// list, only the nodes themselves can send requests.
+	var clientTable []*network.ClientInfo
+	if len(os.Args) > 3 {
+		clientTable = loadClientTable(os.Args[3])
+	}
+	if genesis == nil {
+		AssertError(loadPublicKeys(nodeTable, nil))
 	}
+	AssertError(loadPublicKeys(nil, clientTable))
 
-	// Make NodeID PriveKey
-	privKeyFile := fmt.Sprintf("keys/%s.priv", nodeID)
-	privbytes, err := ioutil.ReadFile(privKeyFile)
+	signer, err := loadPrivateKey(nodeID)
 	AssertError(err)
-	decodePrivKey := PrivateKeyDecode(privbytes)
 
-	server := network.NewServer(nodeID, nodeTable, viewID, decodePrivKey)
+	// Messages are signed for one cluster; nodes sharing keys
+	// across clusters must set PBFT_CLUSTER_ID.
+	clusterID := os.Getenv("PBFT_CLUSTER_ID")
+	if genesis != nil {
+		if clusterID != "" && clusterID != genesis.ClusterID {
+			AssertError(fmt.Errorf("PBFT_CLUSTER_ID is %q, but the genesis is for %q", clusterID, genesis.ClusterID))
+		}
+		clusterID = genesis.ClusterID
+		viewID = genesis.InitialView
+
+		// The private key must be the one the members agreed on.
+		if nodeInfo := findNodeInfo(nodeTable, nodeID); nodeInfo != nil {
+			AssertError(checkSigner(nodeID, signer, nodeInfo.PubKey))
+		}
+	}
+	if clusterID == "" {
+		clusterID = network.DefaultClusterID
+	}
+
+	server := network.NewServer(nodeID, nodeTable, clientTable, viewID, clusterID, signer)
 
 	if server != nil {
+		if genesis != nil {
+			server.UseParams(genesis.Params)
+		}
+		server.UseNextKey(func() (consensus.Signer, error) {
+			return loadNextPrivateKey(nodeID)
+		})
+
+		// PBFT_TRANSPORT=grpc sends replica traffic over gRPC
+		// streams; nodes need "grpcUrl" in the node list.
+		if os.Getenv("PBFT_TRANSPORT") == "grpc" {
+			AssertError(server.UseGrpcTransport())
+		}
+
+		// PBFT_DATA_DIR keeps the executed requests on disk for
+		// the commit stream.
+		if dataDir := os.Getenv("PBFT_DATA_DIR"); dataDir != "" {
+			AssertError(server.UseDataDir(dataDir))
+		}
+
+		if tlsDir != "" {
+			tlsConfig, err := network.LoadTLSConfig(tlsDir, nodeID, nodeTable, clientTable)
+			AssertError(err)
+			server.UseTLS(tlsConfig)
+		}
+
 		server.Start()
 	}
 }
 
+// Load the node table from a genesis document, which is verified, or
+// from a node list.
+func loadClusterConfig(path string) ([]*network.NodeInfo, *network.Genesis) {
+	data, err := os.ReadFile(path)
+	AssertError(err)
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		return loadNodeTable(path), nil
+	}
+
+	genesis, err := network.LoadGenesis(path)
+	AssertError(err)
+	nodeTable, err := genesis.Verify()
+	if err != nil {
+		AssertError(fmt.Errorf("genesis %s: %v", path, err))
+	}
+
+	fmt.Printf("Genesis %s verified: cluster %s, %d members, f=%d\n",
+	           genesis.Digest(), genesis.ClusterID, len(nodeTable), genesis.F)
+
+	return nodeTable, genesis
+}
+
+func loadNodeTable(nodeListFile string) []*network.NodeInfo {
+	var nodeTable []*network.NodeInfo
+
+	jsonFile, err := os.Open(nodeListFile)
+	AssertError(err)
+	defer jsonFile.Close()
+
+	err = json.NewDecoder(jsonFile).Decode(&nodeTable)
+	AssertError(err)
+
+	return nodeTable
+}
+
+func loadClientTable(clientListFile string) []*network.ClientInfo {
+	var clientTable []*network.ClientInfo
+
+	jsonFile, err := os.Open(clientListFile)
+	AssertError(err)
+	defer jsonFile.Close()
+
+	err = json.NewDecoder(jsonFile).Decode(&clientTable)
+	AssertError(err)
+
+	return clientTable
+}
+
+// Load the client histories saved by the clients (e.g., by bench) and
+// collect the committed logs from the running nodes, then check the
+// history is linearizable and the committed logs of all nodes agree.
+func check(nodeTable []*network.NodeInfo, histories []string) {
+	var ops []checker.Operation
+	logs := make(map[string][]checker.CommittedEntry)
+
+	for _, path := range histories {
+		history, err := checker.LoadHistory(path)
+		AssertError(err)
+		ops = append(ops, history...)
+
+		fmt.Printf("%s: %d operations\n", path, len(history))
+	}
+
+	for _, nodeInfo := range nodeTable {
+		entries, err := checker.FetchCommitted(nodeInfo.Url)
+		AssertError(err)
+		logs[nodeInfo.NodeID] = entries
+
+		fmt.Printf("%s: %d committed requests\n", nodeInfo.NodeID, len(entries))
+	}
+
+	if err := checker.CheckLinearizable(ops); err != nil {
+		AssertError(fmt.Errorf("linearizability: %v", err))
+	}
+	fmt.Println("History is linearizable")
+
+	if err := checker.CheckCommittedPrefix(logs); err != nil {
+		AssertError(fmt.Errorf("replica agreement: %v", err))
+	}
+	fmt.Println("Committed logs agree on their common prefix")
+}
+
 func AssertError(err error) {
 	if err == nil {
 		return
@@ -0,0 +1,173 @@
+// Admission control of client requests.
+//
+// Requests are admitted where they enter the cluster: at the gateway
+// and the Submit RPC of the replica the client sends them to, normally
+// the primary. A new request is refused with a "busy, retry later"
+// response when its client exceeds its rate (Params.ClientRateLimit),
+// when all clients together exceed theirs (Params.RateLimit), or when
+// the replica already keeps Params.MaxPendingRequests requests that
+// are not executed yet. The gateway responds 503 with a Retry-After
+// header, and the Submit RPC with ResourceExhausted and the time in
+// the "retry-after-ms" header; the client package waits and submits
+// the request again. Retransmitted requests are always admitted, so
+// their clients get the replies.
+//
+// Requests relayed from other replicas were admitted where they
+// entered, and are never dropped for the limits of this replica, nor
+// are those the primary orders (see getPrePrepareState): a backup
+// dropping them would hold the PRE-PREPARE messages, and vote out a
+// correct primary under load. The primary orders at most
+// maxOrderedRequests requests at a time, and the others wait in the
+// queue until executions make room, which bounds the states and their
+// goroutines.
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Time a client waits before retrying a request refused for the full
+// pending queue.
+const busyRetryAfter = 500 * time.Millisecond
+
+// Requests the primary orders and has not executed yet, at most.
+const maxOrderedRequests = 64
+
+// gRPC header of the milliseconds to wait before submitting a refused
+// request again.
+const RetryAfterHeader = "retry-after-ms"
+
+// BusyError refuses a request, which may be submitted again later.
+type BusyError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (err *BusyError) Error() string {
+	return fmt.Sprintf("busy, retry later (after %v): %s", err.RetryAfter, err.Reason)
+}
+
+// Token bucket of requests per second, with a burst of one second.
+type rateLimiter struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, now time.Time) *rateLimiter {
+	return &rateLimiter{rate: rate, tokens: math.Max(rate, 1), last: now}
+}
+
+// Time until a token is available.
+func (limiter *rateLimiter) wait(now time.Time) time.Duration {
+	limiter.tokens = math.Min(limiter.tokens + now.Sub(limiter.last).Seconds() * limiter.rate, math.Max(limiter.rate, 1))
+	limiter.last = now
+	if limiter.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - limiter.tokens) / limiter.rate * float64(time.Second))
+}
+
+// Admit a request entering the cluster at this replica, or refuse it
+// with a BusyError.
+func (node *Node) admitRequest(reqMsg *consensus.RequestMsg) error {
+	if !node.isNewRequest(reqMsg) {
+		return nil
+	}
+
+	if err := node.checkPendingQueue(); err != nil {
+		return err
+	}
+
+	node.rateLimitersMutex.Lock()
+	defer node.rateLimitersMutex.Unlock()
+
+	// Take a token from both buckets, or from none.
+	now := time.Now()
+	if err := node.checkRate(reqMsg.ClientID, node.Params.ClientRateLimit, now); err != nil {
+		return err
+	}
+	if err := node.checkRate("", node.Params.RateLimit, now); err != nil {
+		return err
+	}
+	for _, limiter := range []*rateLimiter{
+		node.rateLimiter(reqMsg.ClientID, node.Params.ClientRateLimit, now),
+		node.rateLimiter("", node.Params.RateLimit, now),
+	} {
+		if limiter != nil {
+			limiter.tokens--
+		}
+	}
+
+	return nil
+}
+
+// Bucket of the client, or of all clients for "", or nil without a
+// limit. Must be called with rateLimitersMutex held.
+func (node *Node) rateLimiter(key string, rate float64, now time.Time) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	limiter := node.rateLimiters[key]
+	if limiter == nil || limiter.rate != rate {
+		limiter = newRateLimiter(rate, now)
+		node.rateLimiters[key] = limiter
+	}
+
+	return limiter
+}
+
+// Check a token is available in the bucket. Must be called with
+// rateLimitersMutex held.
+func (node *Node) checkRate(key string, rate float64, now time.Time) error {
+	limiter := node.rateLimiter(key, rate, now)
+	if limiter == nil {
+		return nil
+	}
+
+	wait := limiter.wait(now)
+	if wait == 0 {
+		return nil
+	}
+	reason := fmt.Sprintf("more than %g requests per second", rate)
+	if key != "" {
+		reason += " from " + key
+	}
+
+	return &BusyError{Reason: reason, RetryAfter: wait}
+}
+
+// Refuse a new request if the replica keeps as many requests as it may.
+func (node *Node) checkPendingQueue() error {
+	if !node.isPendingQueueFull() {
+		return nil
+	}
+
+	return &BusyError{
+		Reason:     fmt.Sprintf("%d requests are pending", node.Params.maxPendingRequests()),
+		RetryAfter: busyRetryAfter,
+	}
+}
+
+// Check the replica keeps as many requests as it may.
+func (node *Node) isPendingQueueFull() bool {
+	node.pendingRequestsMutex.Lock()
+	defer node.pendingRequestsMutex.Unlock()
+
+	return len(node.pendingRequests) >= node.Params.maxPendingRequests()
+}
+
+// Check the request is newer than those accepted from its client,
+// rather than retransmitted (see acceptRequest).
+func (node *Node) isNewRequest(reqMsg *consensus.RequestMsg) bool {
+	node.clientRequestsMutex.Lock()
+	defer node.clientRequestsMutex.Unlock()
+
+	return reqMsg.Timestamp > node.lastRequests[reqMsg.ClientID]
+}
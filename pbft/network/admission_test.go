@@ -0,0 +1,71 @@
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"testing"
+)
+
+func TestAdmitRequest(t *testing.T) {
+	node, _ := newBackupNode(t)
+	node.Params.ClientRateLimit = 1
+	node.rateLimiters = make(map[string]*rateLimiter)
+
+	reqMsg := &consensus.RequestMsg{ClientID: "Client1", Timestamp: 1}
+	if err := node.admitRequest(reqMsg); err != nil {
+		t.Fatalf("request is not admitted: %v", err)
+	}
+
+	// Another request of the client waits for the next token, while
+	// retransmissions and requests of other clients are admitted.
+	if err := node.admitRequest(&consensus.RequestMsg{ClientID: "Client1", Timestamp: 2}); err == nil {
+		t.Errorf("request over the rate of its client is admitted")
+	}
+	node.lastRequests["Client1"] = 1
+	if err := node.admitRequest(reqMsg); err != nil {
+		t.Errorf("retransmitted request is not admitted: %v", err)
+	}
+	if err := node.admitRequest(&consensus.RequestMsg{ClientID: "Node3", Timestamp: 1}); err != nil {
+		t.Errorf("request of another client is not admitted: %v", err)
+	}
+}
+
+// Only this replica is over its limits, so it must keep the requests
+// relayed to it and those the primary orders, rather than hold the
+// PRE-PREPARE messages and vote out the primary.
+func TestRelayedRequestsOverLimits(t *testing.T) {
+	node, client := newBackupNode(t)
+	node.Params.ClientRateLimit = 1
+	node.Params.MaxPendingRequests = 1
+	node.rateLimiters = make(map[string]*rateLimiter)
+
+	first := signedRequest(t, client, 1, "k=1")
+	if err := node.admitRequest(first); err != nil {
+		t.Fatalf("request is not admitted: %v", err)
+	}
+	node.GetReq(first)
+
+	relayed := signedRequest(t, client, 2, "k=2")
+	if err := node.admitRequest(relayed); err == nil {
+		t.Fatalf("request over the limits is admitted")
+	}
+	node.GetReq(relayed)
+	if len(node.pendingRequests) != 2 {
+		t.Fatalf("%d requests are pending, want the 2 relayed", len(node.pendingRequests))
+	}
+
+	ordered := signedRequest(t, client, 3, "k=3")
+	for i, request := range []*consensus.RequestMsg{first, relayed, ordered} {
+		state, err := node.getPrePrepareState(prePrepareOf(request, int64(i + 1)))
+		if state == nil || err != nil {
+			t.Fatalf("PRE-PREPARE of request %d is not resolved: %v, %v", i + 1, state, err)
+		}
+	}
+	if node.IsViewChanging {
+		t.Errorf("replica over its limits votes for a view change")
+	}
+	select {
+	case errs := <-node.MsgError:
+		t.Errorf("replica over its limits drops a request: %v", errs)
+	default:
+	}
+}
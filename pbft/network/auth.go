@@ -0,0 +1,257 @@
+// Authentication of subscribers to the hub of this node.
+//
+// Subscribers are identified during the websocket upgrade handshake,
+// by their TLS client certificate if the node uses TLS, or else by
+// handshake headers signed with their key. The headers sign a nonce
+// the subscriber gets from /nonce first, which the node accepts once.
+// Replicas get the messages of the node for all replicas and the
+// replies to their own requests; clients get only the replies to their
+// own requests.
+//
+// Callers of the gRPC services are identified the same way, with the
+// headers as metadata signed for "/grpc" and a nonce from the Nonce
+// RPC: replica streams are open to replicas only, and their messages
+// must be from the replica itself.
+//
+// Key rotation requests to /rotatekey carry the same headers, signed
+// for "/rotatekey" with the current key of the node itself, also with
+// TLS, as the operator runs the rotatekey command with a client
+// certificate.
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Headers of the subscription handshake.
+const HeaderSubscriber = "X-Pbft-Subscriber"
+const HeaderSubscribeNonce = "X-Pbft-Nonce"
+const HeaderSubscribeSignature = "X-Pbft-Signature"
+
+// Nonces issued by the node are valid for this long, and each of them
+// is accepted in one handshake only, so that a recorded handshake
+// cannot be replayed.
+const NonceLifetime = 30 * time.Second
+
+// The handshake is signed for one node, one purpose (e.g.,
+// "/subscribe") and one nonce of the node, so it cannot be replayed to
+// the others, for another purpose, or again.
+func handshakeSigningBytes(purpose string, clusterID string, subscriberID string, nodeID string, nonce string) []byte {
+	payload := []byte(nodeID + "/" + nonce)
+
+	return consensus.SigningBytes(consensus.ProtocolVersion, clusterID, purpose, subscriberID, payload)
+}
+
+// SubscribeHeader returns the handshake headers for subscribing to the
+// given node as the given replica or client, with a nonce of the node
+// (see FetchNonce).
+func SubscribeHeader(clusterID string, subscriberID string, nodeID string, nonce string, signer consensus.Signer) (http.Header, error) {
+	return handshakeHeader("/subscribe", clusterID, subscriberID, nodeID, nonce, signer)
+}
+
+// RotateKeyHeader returns the headers for requesting the key rotation
+// of the given node, signed with its current key.
+func RotateKeyHeader(clusterID string, nodeID string, nonce string, signer consensus.Signer) (http.Header, error) {
+	return handshakeHeader("/rotatekey", clusterID, nodeID, nodeID, nonce, signer)
+}
+
+// CallMetadata returns the gRPC metadata authenticating a call to the
+// given node as the given replica or client, for nodes without TLS,
+// with a nonce from the Nonce RPC of the node.
+func CallMetadata(clusterID string, callerID string, nodeID string, nonce string, signer consensus.Signer) (metadata.MD, error) {
+	header, err := handshakeHeader("/grpc", clusterID, callerID, nodeID, nonce, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	md := metadata.MD{}
+	for key, values := range header {
+		md.Append(key, values...)
+	}
+
+	return md, nil
+}
+
+func handshakeHeader(purpose string, clusterID string, subscriberID string, nodeID string, nonce string, signer consensus.Signer) (http.Header, error) {
+	signature, err := signer.Sign(handshakeSigningBytes(purpose, clusterID, subscriberID, nodeID, nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header)
+	header.Set(HeaderSubscriber, subscriberID)
+	header.Set(HeaderSubscribeNonce, nonce)
+	header.Set(HeaderSubscribeSignature, base64.StdEncoding.EncodeToString(signature))
+
+	return header, nil
+}
+
+// FetchNonce gets a nonce for a handshake from the /nonce endpoint of
+// the node at the URL (e.g., "http://localhost:1111").
+func FetchNonce(client *http.Client, baseURL string) (string, error) {
+	resp, err := client.Get(baseURL + "/nonce")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("nonce from %s: %s", baseURL, resp.Status)
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+// Serve a fresh nonce for a handshake.
+func (server *Server) serveNonce(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprintln(w, server.node.issueNonce(time.Now()))
+}
+
+// Issue a nonce: the time it is issued, authenticated with a secret of
+// the node, so the node keeps no state for the nonces it issues.
+func (node *Node) issueNonce(now time.Time) string {
+	issued := make([]byte, 8)
+	binary.BigEndian.PutUint64(issued, uint64(now.UnixNano()))
+
+	mac := hmac.New(sha256.New, node.nonceKey)
+	mac.Write(issued)
+
+	return base64.RawURLEncoding.EncodeToString(append(issued, mac.Sum(nil)...))
+}
+
+// Check the nonce is issued by this node and has not expired.
+func (node *Node) checkNonce(nonce string, now time.Time) error {
+	data, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(data) != 8 + sha256.Size {
+		return errors.New("invalid nonce")
+	}
+
+	mac := hmac.New(sha256.New, node.nonceKey)
+	mac.Write(data[:8])
+	if !hmac.Equal(mac.Sum(nil), data[8:]) {
+		return errors.New("nonce is not issued by this node")
+	}
+
+	issued := time.Unix(0, int64(binary.BigEndian.Uint64(data[:8])))
+	if age := now.Sub(issued); age > NonceLifetime || age < 0 {
+		return fmt.Errorf("nonce is issued %v ago", age)
+	}
+
+	return nil
+}
+
+// Accept the nonce of an authenticated handshake once. Only nonces of
+// signed handshakes are kept, until they expire, so the memory is
+// bounded by the handshakes of the replicas and the clients.
+func (node *Node) useNonce(nonce string, now time.Time) error {
+	node.usedNoncesMutex.Lock()
+	defer node.usedNoncesMutex.Unlock()
+
+	for used, expiry := range node.usedNonces {
+		if now.After(expiry) {
+			delete(node.usedNonces, used)
+		}
+	}
+	if _, ok := node.usedNonces[nonce]; ok {
+		return errors.New("handshake is replayed")
+	}
+	node.usedNonces[nonce] = now.Add(NonceLifetime)
+
+	return nil
+}
+
+// Identify the subscriber by its TLS client certificate or, without
+// TLS, by the signed handshake headers.
+func (server *Server) authenticate(r *http.Request) (*PeerIdentity, error) {
+	if server.tls != nil {
+		return server.tls.IdentifyConn(r.TLS)
+	}
+
+	return server.node.verifyHandshake("/subscribe", r.Header.Get)
+}
+
+// Identify the caller of a gRPC service by its TLS client certificate
+// or, without TLS, by the signed metadata of the call.
+func authenticateCall(ctx context.Context, config *TLSConfig, node *Node) (*PeerIdentity, error) {
+	if config != nil {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return nil, errors.New("no peer of the call")
+		}
+		info, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok {
+			return nil, errors.New("not a TLS connection")
+		}
+		return config.IdentifyConn(&info.State)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	return node.verifyHandshake("/grpc", func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	})
+}
+
+// Identify the signer of the handshake headers for the purpose.
+func (node *Node) verifyHandshake(purpose string, header func(key string) string) (*PeerIdentity, error) {
+	subscriberID := header(HeaderSubscriber)
+	if subscriberID == "" {
+		return nil, errors.New("no subscriber identity")
+	}
+
+	now := time.Now()
+	nonce := header(HeaderSubscribeNonce)
+	if err := node.checkNonce(nonce, now); err != nil {
+		return nil, fmt.Errorf("subscriber %s: %v", subscriberID, err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(header(HeaderSubscribeSignature))
+	if err != nil {
+		return nil, fmt.Errorf("subscriber %s: invalid signature encoding", subscriberID)
+	}
+	data := handshakeSigningBytes(purpose, node.ClusterID, subscriberID, node.MyInfo.NodeID, nonce)
+
+	var identity *PeerIdentity
+	if verifier := node.Keys.Verifier(subscriberID); verifier != nil {
+		if !verifier.Verify(signature, data) {
+			return nil, fmt.Errorf("invalid handshake signature of replica %s", subscriberID)
+		}
+		identity = &PeerIdentity{NodeID: subscriberID}
+	} else if clientInfo := findClientInfo(node.ClientTable, subscriberID); clientInfo != nil {
+		if !clientInfo.PubKey.Verify(signature, data) {
+			return nil, fmt.Errorf("invalid handshake signature of client %s", subscriberID)
+		}
+		identity = &PeerIdentity{ClientID: subscriberID}
+	} else {
+		return nil, fmt.Errorf("unknown subscriber %q", subscriberID)
+	}
+
+	if err := node.useNonce(nonce, now); err != nil {
+		return nil, fmt.Errorf("subscriber %s: %v", subscriberID, err)
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,97 @@
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func newSigner(t *testing.T) consensus.Signer {
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := consensus.NewSigner(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+func TestAuthenticateCall(t *testing.T) {
+	node1, node2, client1, stranger := newSigner(t), newSigner(t), newSigner(t), newSigner(t)
+	nodeTable := []*NodeInfo{
+		{NodeID: "Node1", PubKey: node1.Verifier()},
+		{NodeID: "Node2", PubKey: node2.Verifier()},
+	}
+	newNode := func(nodeInfo *NodeInfo) *Node {
+		node := &Node{
+			ClusterID:   "pbft",
+			MyInfo:      nodeInfo,
+			NodeTable:   nodeTable,
+			ClientTable: []*ClientInfo{{ClientID: "Client1", PubKey: client1.Verifier()}},
+			Keys:        NewKeyRing(nodeTable),
+			nonceKey:    make([]byte, 32),
+			usedNonces:  make(map[string]time.Time),
+		}
+		if _, err := rand.Read(node.nonceKey); err != nil {
+			t.Fatal(err)
+		}
+		return node
+	}
+	node, other := newNode(nodeTable[0]), newNode(nodeTable[1])
+	used := node.issueNonce(time.Now())
+
+	tests := []struct {
+		name     string
+		cluster  string
+		callerID string
+		nodeID   string
+		nonce    string
+		signer   consensus.Signer
+		identity PeerIdentity
+		ok       bool
+	}{
+		{"replica", "pbft", "Node2", "Node1", used, node2, PeerIdentity{NodeID: "Node2"}, true},
+		{"client", "pbft", "Client1", "Node1", node.issueNonce(time.Now()), client1, PeerIdentity{ClientID: "Client1"}, true},
+		{"replayed handshake", "pbft", "Node2", "Node1", used, node2, PeerIdentity{}, false},
+		{"nonce of another node", "pbft", "Node2", "Node1", other.issueNonce(time.Now()), node2, PeerIdentity{}, false},
+		{"expired nonce", "pbft", "Node2", "Node1", node.issueNonce(time.Now().Add(-2 * NonceLifetime)), node2, PeerIdentity{}, false},
+		{"replica with another key", "pbft", "Node2", "Node1", node.issueNonce(time.Now()), node1, PeerIdentity{}, false},
+		{"client with another key", "pbft", "Client1", "Node1", node.issueNonce(time.Now()), stranger, PeerIdentity{}, false},
+		{"unknown caller", "pbft", "Client2", "Node1", node.issueNonce(time.Now()), stranger, PeerIdentity{}, false},
+		{"call to another node", "pbft", "Node2", "Node3", node.issueNonce(time.Now()), node2, PeerIdentity{}, false},
+		{"call to another cluster", "other", "Node2", "Node1", node.issueNonce(time.Now()), node2, PeerIdentity{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			md, err := CallMetadata(test.cluster, test.callerID, test.nodeID, test.nonce, test.signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+
+			identity, err := authenticateCall(ctx, nil, node)
+			if test.ok && err != nil {
+				t.Fatalf("call is refused: %v", err)
+			}
+			if !test.ok && err == nil {
+				t.Fatalf("call is authenticated as %+v", identity)
+			}
+			if test.ok && *identity != test.identity {
+				t.Errorf("call is authenticated as %+v, want %+v", identity, test.identity)
+			}
+		})
+	}
+
+	if _, err := authenticateCall(context.Background(), nil, node); err == nil {
+		t.Errorf("call without metadata is authenticated")
+	}
+}
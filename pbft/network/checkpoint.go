@@ -5,8 +5,6 @@ import (
 	"fmt"
 )
 
-const periodCheckPoint = 5
-
 func (node *Node) GetCheckPoint(CheckPointMsg *consensus.CheckPointMsg) error {
 	LogMsg(CheckPointMsg)
 
@@ -58,7 +56,7 @@ func (node *Node) CheckPoint(msg *consensus.CheckPointMsg) {
 
 	// Checkpoint only once for each sequence number.
 	if node.Checkpointchk(state) && state.GetSuccChkPoint() != 1 {
-		fStableCheckPoint := node.StableCheckPoint + periodCheckPoint
+		fStableCheckPoint := node.StableCheckPoint + node.Params.CheckPointPeriod
 
 		// Delete Checkpoint Message Logs.
 		node.CheckPointMutex.Lock()
@@ -78,6 +76,9 @@ func (node *Node) CheckPoint(msg *consensus.CheckPointMsg) {
 		}
 		node.StatesMutex.Unlock()
 
+		// Keep the executed requests before it in the durable log only.
+		node.trimExecutedLog(fStableCheckPoint)
+
 		// Update checkpoint variables for node and state.
 		state.SetSuccChkPoint(1)
 		node.StableCheckPoint = fStableCheckPoint
@@ -90,10 +91,10 @@ func (node *Node) CheckPoint(msg *consensus.CheckPointMsg) {
 	}
 }
 
-// Check the COMMIT messages, for given `periodCheckPoint` consecutive
+// Check the COMMIT messages, for given `CheckPointPeriod` consecutive
 // sequence numbers, are enough including the messages for the current node.
 func (node *Node) CheckPointMissCheck(sequenceID int64) bool {
-	for i := (sequenceID + 1); i <= (sequenceID + periodCheckPoint); i++ {
+	for i := (sequenceID + 1); i <= (sequenceID + node.Params.CheckPointPeriod); i++ {
 		state, _ := node.getState(i)
 		if state == nil {
 			return false
@@ -5,6 +5,7 @@
 package network
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
 	"time"
@@ -37,6 +38,13 @@ type Client struct {
 
 	// Buffered channel of outbound messages.
 	send chan []byte
+
+	// Replica or client authenticated in the upgrade handshake.
+	identity *PeerIdentity
+
+	// Node ID announced by the HELLO message of a subscribed peer.
+	// Accessed by the hub only.
+	nodeID string
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -61,7 +69,22 @@ func (c *Client) readPump() {
 			break
 		}
 		//log.Println("RECV:", message)
-		c.hub.broadcast <- message
+		var msg PeerMsg
+		if err := json.Unmarshal(message, &msg); err != nil {
+			log.Printf("error: %v", err)
+			continue
+		}
+
+		// Subscribers only receive messages. Replicas acknowledge
+		// them; messages are published by this node only.
+		switch msg.Type {
+		case "/hello", "/ack":
+			if c.identity.NodeID != "" {
+				c.hub.control <- &clientMsg{c, &msg}
+			}
+		default:
+			log.Printf("%s message from %s is dropped", msg.Type, c.identity)
+		}
 	}
 }
 
@@ -86,19 +109,9 @@ func (c *Client) writePump() {
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			// Add queued chat messages to the current websocket message.
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
+			// Each message is a separate websocket message, so the
+			// peer can unmarshal them one by one.
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
 		case <-ticker.C:
@@ -111,14 +124,15 @@ func (c *Client) writePump() {
 	}
 }
 
-// serveWs handles websocket requests from the peer.
-func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
+// serveWs handles websocket requests from the peer, authenticated as
+// the given identity.
+func ServeWs(hub *Hub, identity *PeerIdentity, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256)}
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, PeerSendQueueSize), identity: identity}
 	client.hub.register <- client
 
 	// Allow collection of memory referenced by the caller by doing all work in
@@ -0,0 +1,161 @@
+// Durable log of the requests executed by this replica.
+//
+// With a data directory (PBFT_DATA_DIR), the replica appends the event
+// of each request it executes to <dir>/<nodeID>.events, one JSON
+// CommitEvent per line, and syncs the file before it trims its memory
+// at a stable checkpoint. The commit stream reads the events older
+// than those in memory back from the file, so subscribers can backfill
+// from the first request, also after the replica restarted.
+//
+// A torn last line, left by a crash during an append, is cut off when
+// the file is opened again.
+
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+type eventLog struct {
+	mutex sync.Mutex
+	file  *os.File
+	index []eventOffset // in sequence number order
+	size  int64
+}
+
+// Position of an event in the file.
+type eventOffset struct {
+	sequenceID int64
+	offset     int64
+}
+
+// Open the event log of the node in the directory, creating it if it
+// does not exist.
+func openEventLog(dir string, nodeID string) (*eventLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, nodeID + ".events"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	events := &eventLog{file: file}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		var event CommitEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: event at offset %d: %v", file.Name(), events.size, err)
+		}
+		events.index = append(events.index, eventOffset{sequenceID: event.SequenceID, offset: events.size})
+		events.size += int64(len(line))
+	}
+
+	if err := file.Truncate(events.size); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Sequence number of the last event in the log, or 0 if it is empty.
+func (events *eventLog) last() int64 {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+
+	if len(events.index) == 0 {
+		return 0
+	}
+
+	return events.index[len(events.index) - 1].sequenceID
+}
+
+// Append the event, unless the log has it already, e.g., as the
+// replica executes its log again after a restart.
+func (events *eventLog) append(event *CommitEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+
+	if n := len(events.index); n > 0 && events.index[n - 1].sequenceID >= event.SequenceID {
+		return nil
+	}
+	if _, err := events.file.WriteAt(data, events.size); err != nil {
+		return err
+	}
+	events.index = append(events.index, eventOffset{sequenceID: event.SequenceID, offset: events.size})
+	events.size += int64(len(data))
+
+	return nil
+}
+
+// Flush the appended events to the disk.
+func (events *eventLog) sync() error {
+	return events.file.Sync()
+}
+
+// Events from the given sequence number on, before the sequence
+// number until, up to the given number.
+func (events *eventLog) read(from int64, until int64, limit int) ([]*CommitEvent, error) {
+	events.mutex.Lock()
+	i := sort.Search(len(events.index), func(i int) bool {
+		return events.index[i].sequenceID >= from
+	})
+	j := i
+	for j < len(events.index) && j - i < limit && events.index[j].sequenceID < until {
+		j++
+	}
+	if i == j {
+		events.mutex.Unlock()
+		return nil, nil
+	}
+	start := events.index[i].offset
+	end := events.size
+	if j < len(events.index) {
+		end = events.index[j].offset
+	}
+	events.mutex.Unlock()
+
+	data := make([]byte, end - start)
+	if _, err := events.file.ReadAt(data, start); err != nil {
+		return nil, err
+	}
+
+	logged := make([]*CommitEvent, 0, j - i)
+	for _, line := range bytes.SplitAfter(data, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		var event CommitEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, err
+		}
+		logged = append(logged, &event)
+	}
+
+	return logged, nil
+}
@@ -0,0 +1,112 @@
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func logEvents(t *testing.T, events *eventLog, sequenceIDs ...int64) {
+	for _, sequenceID := range sequenceIDs {
+		event := &CommitEvent{
+			NodeID:     "Node1",
+			SequenceID: sequenceID,
+			Request:    &consensus.RequestMsg{ClientID: "Client1", Timestamp: sequenceID, SequenceID: sequenceID},
+		}
+		if err := events.append(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func sequenceIDsOf(events []*CommitEvent) []int64 {
+	sequenceIDs := make([]int64, len(events))
+	for i, event := range events {
+		sequenceIDs[i] = event.SequenceID
+	}
+
+	return sequenceIDs
+}
+
+func equalSequenceIDs(a []int64, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestEventLogRead(t *testing.T) {
+	events, err := openEventLog(t.TempDir(), "Node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Null requests leave gaps; events logged already are skipped.
+	logEvents(t, events, 1, 2, 4, 5, 2, 6)
+
+	tests := []struct {
+		from   int64
+		until  int64
+		limit  int
+		logged []int64
+	}{
+		{1, math.MaxInt64, 10, []int64{1, 2, 4, 5, 6}},
+		{3, math.MaxInt64, 10, []int64{4, 5, 6}},
+		{1, 5, 10, []int64{1, 2, 4}},
+		{2, math.MaxInt64, 2, []int64{2, 4}},
+		{7, math.MaxInt64, 10, []int64{}},
+	}
+
+	for _, test := range tests {
+		logged, err := events.read(test.from, test.until, test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sequenceIDsOf(logged); !equalSequenceIDs(got, test.logged) {
+			t.Errorf("read(%d, %d, %d) = %v, want %v", test.from, test.until, test.limit, got, test.logged)
+		}
+	}
+}
+
+func TestEventLogReopen(t *testing.T) {
+	dir := t.TempDir()
+	events, err := openEventLog(dir, "Node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logEvents(t, events, 1, 2, 3)
+	events.file.Close()
+
+	// A torn append is cut off.
+	file, err := os.OpenFile(filepath.Join(dir, "Node1.events"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"nodeID":"Node1","sequenceID":4,"req`)
+	file.Close()
+
+	events, err = openEventLog(dir, "Node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.file.Close()
+	if last := events.last(); last != 3 {
+		t.Fatalf("last event is %d, want 3", last)
+	}
+
+	logEvents(t, events, 4)
+	logged, err := events.read(1, math.MaxInt64, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequenceIDsOf(logged); !equalSequenceIDs(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("events are %v after reopening", got)
+	}
+}
@@ -0,0 +1,374 @@
+// Stream of the requests executed by this replica.
+//
+// A websocket subscription to /events receives every request this
+// replica executed from the sequence number in the "from" query
+// parameter on, in sequence number order, and then each request as it
+// is executed. Subscribers are authenticated as on /peer, so they must
+// be replicas or clients in the client table.
+//
+// Each event is a "/event" message signed by the replica, with the
+// request, its digest, sequence number, view and result, and the
+// COMMIT messages of the replicas which committed it, as they signed
+// them. A consumer checks the certificate with the keys of the
+// replicas, so it does not trust the replica which sent the event.
+//
+// After a reconnect, a consumer resubscribes from the sequence number
+// after the last event it received, and the replica backfills the
+// missed events from its log of executed requests. The replica keeps
+// the events after its stable checkpoint in memory, and the older ones
+// in its durable log (see eventlog.go); without one, a subscription
+// from before the kept events is refused with 410 Gone.
+//
+//   ws://localhost:1112/events?from=1
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/pbftpb"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// Request executed by a replica, as streamed on /events.
+type CommitEvent struct {
+	NodeID      string                `json:"nodeID"` // replica which executed it
+	SequenceID  int64                 `json:"sequenceID"`
+	ViewID      int64                 `json:"viewID"`
+	Digest      string                `json:"digest"`
+	Request     *consensus.RequestMsg `json:"request"`
+	Result      string                `json:"result"`
+	Certificate []*SignedCommit       `json:"certificate"` // COMMIT messages, by node ID
+}
+
+// Encodings of the COMMIT messages in certificates.
+const (
+	EncodingJSON  = "json"  // websocket transport
+	EncodingProto = "proto" // gRPC transport, see signingBytes
+)
+
+// COMMIT message of a certificate as its replica signed it. Over gRPC,
+// the marshalled message is the protobuf envelope with the message
+// only, which the signature covers.
+type SignedCommit struct {
+	Encoding string                  `json:"encoding"`
+	Envelope *consensus.SignatureMsg `json:"envelope"`
+}
+
+// Events before the kept ones were trimmed at a stable checkpoint.
+var errEventsTrimmed = errors.New("events are no longer kept")
+
+// Number of logged events sent before the log is read again.
+const eventBatchSize = 64
+
+// Verify the COMMIT message with the keys of its replica.
+func (commit *SignedCommit) Open(clusterID string, keys *KeyRing) (*consensus.VoteMsg, error) {
+	if commit.Envelope == nil {
+		return nil, errors.New("COMMIT message without its envelope")
+	}
+	sigMsg := commit.Envelope
+	if err := checkSignatureMsg(sigMsg, clusterID, keys); err != nil {
+		return nil, err
+	}
+	if sigMsg.MsgType != "/commit" {
+		return nil, fmt.Errorf("%s message from %s in a certificate", sigMsg.MsgType, sigMsg.NodeID)
+	}
+
+	var msg interface{}
+	var err error
+	switch commit.Encoding {
+	case EncodingJSON:
+		msg = &consensus.VoteMsg{}
+		err = json.Unmarshal(sigMsg.MarshalledMsg, msg)
+	case EncodingProto:
+		var env pbftpb.Envelope
+		if err = proto.Unmarshal(sigMsg.MarshalledMsg, &env); err == nil {
+			_, msg, err = msgOfEnvelope(&env)
+		}
+	default:
+		err = fmt.Errorf("unknown encoding %q", commit.Encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("COMMIT message from %s: %v", sigMsg.NodeID, err)
+	}
+
+	commitMsg, ok := msg.(*consensus.VoteMsg)
+	if !ok || !msgOfType("/commit", commitMsg) || commitMsg.NodeID != sigMsg.NodeID {
+		return nil, fmt.Errorf("COMMIT message from %s is not its own COMMIT", sigMsg.NodeID)
+	}
+
+	return commitMsg, nil
+}
+
+// COMMIT message kept with its signed envelope.
+type keptCommit struct {
+	commitMsg *consensus.VoteMsg
+	signed    *SignedCommit
+}
+
+// Keep the COMMIT message from the sender as it signed it, for the
+// certificate of the request. Only the last one of each replica is
+// kept for a sequence number, within a window above the stable
+// checkpoint, so faulty replicas cannot grow the kept messages.
+func (node *Node) keepSignedCommit(sender string, msg interface{}, signed *SignedCommit) {
+	commitMsg, ok := msg.(*consensus.VoteMsg)
+	if !ok || !msgOfType("/commit", commitMsg) || commitMsg.NodeID != sender {
+		return
+	}
+	if commitMsg.SequenceID < node.StableCheckPoint ||
+	   commitMsg.SequenceID > node.StableCheckPoint + maxOrderedRequests + 2 * node.Params.CheckPointPeriod {
+		return
+	}
+
+	node.signedCommitsMutex.Lock()
+	defer node.signedCommitsMutex.Unlock()
+
+	commits := node.signedCommits[commitMsg.SequenceID]
+	if commits == nil {
+		commits = make(map[string]*keptCommit)
+		node.signedCommits[commitMsg.SequenceID] = commits
+	}
+	commits[sender] = &keptCommit{commitMsg: commitMsg, signed: signed}
+}
+
+// Keep the COMMIT message of this node, signed as over the websocket
+// transport, as it reaches the certificate before its own copy.
+func (node *Node) keepOwnCommit(commitMsg *consensus.VoteMsg) {
+	peerMsg, err := signPeerMsg("/commit", commitMsg, node.ClusterID, node.MyInfo.NodeID, node.Signer)
+	if err != nil {
+		node.MsgError <- []error{err}
+		return
+	}
+	var sigMsg consensus.SignatureMsg
+	if err := json.Unmarshal(peerMsg.Msg, &sigMsg); err != nil {
+		node.MsgError <- []error{err}
+		return
+	}
+
+	node.keepSignedCommit(node.MyInfo.NodeID, commitMsg, &SignedCommit{Encoding: EncodingJSON, Envelope: &sigMsg})
+}
+
+// Signed COMMIT messages matching those the request was committed with.
+func (node *Node) signedCertificate(sequenceID int64, commitMsgs []*consensus.VoteMsg) []*SignedCommit {
+	node.signedCommitsMutex.Lock()
+	defer node.signedCommitsMutex.Unlock()
+
+	var certificate []*SignedCommit
+	for _, commitMsg := range commitMsgs {
+		kept := node.signedCommits[sequenceID][commitMsg.NodeID]
+		if kept != nil && kept.commitMsg.ViewID == commitMsg.ViewID && kept.commitMsg.Digest == commitMsg.Digest {
+			certificate = append(certificate, kept.signed)
+		}
+	}
+
+	return certificate
+}
+
+// Persist the executed requests before the stable checkpoint, and drop
+// them from memory with their COMMIT messages.
+func (node *Node) trimExecutedLog(stableCheckPoint int64) {
+	if node.eventLog != nil {
+		if err := node.eventLog.sync(); err != nil {
+			node.MsgError <- []error{fmt.Errorf("events are kept in memory: %v", err)}
+			return
+		}
+	}
+
+	node.executedRequestsMutex.Lock()
+	i := sort.Search(len(node.executedLog), func(i int) bool {
+		return node.executedLog[i].request.SequenceID >= stableCheckPoint
+	})
+	node.executedLog = append([]*executedRequest(nil), node.executedLog[i:]...)
+	if stableCheckPoint > node.trimmedBefore {
+		node.trimmedBefore = stableCheckPoint
+	}
+	node.executedRequestsMutex.Unlock()
+
+	node.signedCommitsMutex.Lock()
+	for sequenceID := range node.signedCommits {
+		if sequenceID < stableCheckPoint {
+			delete(node.signedCommits, sequenceID)
+		}
+	}
+	node.signedCommitsMutex.Unlock()
+}
+
+// Check the event is consistent with its certificate of COMMIT
+// messages signed by the given number of distinct replicas.
+func (event *CommitEvent) Check(quorum int, clusterID string, keys *KeyRing) error {
+	if event.Request == nil || event.Request.SequenceID != event.SequenceID {
+		return fmt.Errorf("event %d does not carry its request", event.SequenceID)
+	}
+	if digest := event.Request.Digest(); digest != event.Digest {
+		return fmt.Errorf("digest of the request of event %d is %s, not %s", event.SequenceID, digest, event.Digest)
+	}
+
+	voters := make(map[string]bool)
+	for _, commit := range event.Certificate {
+		commitMsg, err := commit.Open(clusterID, keys)
+		if err != nil {
+			return fmt.Errorf("certificate of event %d: %v", event.SequenceID, err)
+		}
+		if commitMsg.MsgType != consensus.CommitMsg || commitMsg.SequenceID != event.SequenceID ||
+		   commitMsg.ViewID != event.ViewID || commitMsg.Digest != event.Digest {
+			return fmt.Errorf("certificate of event %d has a message of %s for sequence number %d in view %d",
+			                  event.SequenceID, commitMsg.NodeID, commitMsg.SequenceID, commitMsg.ViewID)
+		}
+		voters[commitMsg.NodeID] = true
+	}
+	if len(voters) < quorum {
+		return fmt.Errorf("certificate of event %d has %d COMMIT messages, not %d", event.SequenceID, len(voters), quorum)
+	}
+
+	return nil
+}
+
+// Stream the executed requests to an authenticated subscriber.
+func (server *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	from := int64(1)
+	if value := r.URL.Query().Get("from"); value != "" {
+		var err error
+		if from, err = strconv.ParseInt(value, 10, 64); err != nil || from < 1 {
+			http.Error(w, "from must be a sequence number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	identity, err := server.authenticate(r)
+	if err != nil {
+		log.Printf("subscription from %s rejected: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if _, _, err := server.node.commitEventsFrom(from, 0); err != nil {
+		http.Error(w, fmt.Sprintf("events from %d: %v", from, err), http.StatusGone)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	// Subscribers send nothing; read to handle pongs and the close.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(maxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error { conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	log.Printf("%s subscribed to events from %d", identity, from)
+	server.node.streamEvents(conn, from, closed)
+}
+
+// Send the executed requests from the given sequence number on until
+// the subscriber is gone.
+func (node *Node) streamEvents(conn *websocket.Conn, from int64, closed <-chan struct{}) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		events, executed, err := node.commitEventsFrom(from, eventBatchSize)
+		if err != nil {
+			log.Printf("events from %d: %v", from, err)
+			return
+		}
+		for _, event := range events {
+			peerMsg, err := signPeerMsg("/event", event, node.ClusterID, node.MyInfo.NodeID, node.Signer)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			data, err := json.Marshal(peerMsg)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+			from = event.SequenceID + 1
+		}
+		if len(events) > 0 {
+			continue
+		}
+
+		select {
+		case <-executed:
+		case <-closed:
+			return
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Events of the executed requests from the given sequence number on,
+// up to the given number, and a channel closed at the next execution.
+// Those before the events in memory are read from the durable log.
+func (node *Node) commitEventsFrom(from int64, limit int) ([]*CommitEvent, <-chan struct{}, error) {
+	node.executedRequestsMutex.RLock()
+	defer node.executedRequestsMutex.RUnlock()
+
+	// The log is in sequence number order, as requests are executed.
+	i := sort.Search(len(node.executedLog), func(i int) bool {
+		return node.executedLog[i].request.SequenceID >= from
+	})
+
+	if i == 0 && from < node.trimmedBefore {
+		if node.eventLog == nil {
+			return nil, nil, errEventsTrimmed
+		}
+		until := int64(math.MaxInt64)
+		if len(node.executedLog) > 0 {
+			until = node.executedLog[0].request.SequenceID
+		}
+		events, err := node.eventLog.read(from, until, limit)
+		if err != nil || len(events) > 0 {
+			return events, node.executedSignal, err
+		}
+	}
+
+	var events []*CommitEvent
+	for ; i < len(node.executedLog) && len(events) < limit; i++ {
+		events = append(events, node.commitEvent(node.executedLog[i]))
+	}
+
+	return events, node.executedSignal, nil
+}
+
+func (node *Node) commitEvent(executed *executedRequest) *CommitEvent {
+	return &CommitEvent{
+		NodeID:      node.MyInfo.NodeID,
+		SequenceID:  executed.request.SequenceID,
+		ViewID:      executed.reply.ViewID,
+		Digest:      executed.digest,
+		Request:     executed.request,
+		Result:      executed.reply.Result,
+		Certificate: executed.certificate,
+	}
+}
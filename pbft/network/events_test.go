@@ -0,0 +1,126 @@
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"encoding/json"
+	"testing"
+)
+
+// COMMIT message signed as over the websocket transport.
+func jsonCommit(t *testing.T, commitMsg *consensus.VoteMsg, signer consensus.Signer) *SignedCommit {
+	peerMsg, err := signPeerMsg("/commit", commitMsg, "pbft", commitMsg.NodeID, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sigMsg consensus.SignatureMsg
+	if err := json.Unmarshal(peerMsg.Msg, &sigMsg); err != nil {
+		t.Fatal(err)
+	}
+
+	return &SignedCommit{Encoding: EncodingJSON, Envelope: &sigMsg}
+}
+
+// COMMIT message signed as over the gRPC transport.
+func protoCommit(t *testing.T, commitMsg *consensus.VoteMsg, signer consensus.Signer) *SignedCommit {
+	transport := &grpcTransport{myInfo: &NodeInfo{NodeID: commitMsg.NodeID}, clusterID: "pbft", signer: signer}
+	env, err := transport.signedEnvelope("/commit", commitMsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not signed, so not kept.
+	env.Epoch, env.Seq = 7, 42
+
+	commit, err := signedCommitOf(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return commit
+}
+
+func TestCommitEventCheck(t *testing.T) {
+	signers := make(map[string]consensus.Signer)
+	var nodeTable []*NodeInfo
+	for _, nodeID := range []string{"Node1", "Node2", "Node3", "Node4"} {
+		signers[nodeID] = newSigner(t)
+		nodeTable = append(nodeTable, &NodeInfo{NodeID: nodeID, PubKey: signers[nodeID].Verifier()})
+	}
+	keys := NewKeyRing(nodeTable)
+
+	request := &consensus.RequestMsg{ClientID: "Client1", Timestamp: 1, Operation: "Op1", SequenceID: 3}
+	digest := request.Digest()
+	commit := func(nodeID string) *consensus.VoteMsg {
+		return &consensus.VoteMsg{ViewID: 1, SequenceID: 3, Digest: digest, NodeID: nodeID, MsgType: consensus.CommitMsg}
+	}
+	event := func(certificate ...*SignedCommit) *CommitEvent {
+		return &CommitEvent{NodeID: "Node1", SequenceID: 3, ViewID: 1, Digest: digest, Request: request, Certificate: certificate}
+	}
+
+	otherDigest := commit("Node3")
+	otherDigest.Digest = "other"
+	forged := jsonCommit(t, commit("Node4"), signers["Node1"])
+	forged.Envelope.NodeID = "Node4"
+	misnamed := jsonCommit(t, commit("Node3"), signers["Node2"])
+	misnamed.Envelope.NodeID = "Node2"
+
+	tests := []struct {
+		name  string
+		event *CommitEvent
+		valid bool
+	}{
+		{
+			name:  "signed over websockets",
+			event: event(jsonCommit(t, commit("Node1"), signers["Node1"]),
+			             jsonCommit(t, commit("Node2"), signers["Node2"]),
+			             jsonCommit(t, commit("Node3"), signers["Node3"])),
+			valid: true,
+		},
+		{
+			name:  "signed over both transports",
+			event: event(jsonCommit(t, commit("Node1"), signers["Node1"]),
+			             protoCommit(t, commit("Node2"), signers["Node2"]),
+			             protoCommit(t, commit("Node4"), signers["Node4"])),
+			valid: true,
+		},
+		{
+			name:  "too few replicas",
+			event: event(protoCommit(t, commit("Node1"), signers["Node1"]),
+			             protoCommit(t, commit("Node2"), signers["Node2"]),
+			             protoCommit(t, commit("Node2"), signers["Node2"])),
+			valid: false,
+		},
+		{
+			name:  "signed by another replica",
+			event: event(jsonCommit(t, commit("Node1"), signers["Node1"]),
+			             jsonCommit(t, commit("Node2"), signers["Node2"]),
+			             forged),
+			valid: false,
+		},
+		{
+			name:  "COMMIT of another replica",
+			event: event(jsonCommit(t, commit("Node1"), signers["Node1"]),
+			             jsonCommit(t, commit("Node4"), signers["Node4"]),
+			             misnamed),
+			valid: false,
+		},
+		{
+			name:  "COMMIT of another request",
+			event: event(jsonCommit(t, commit("Node1"), signers["Node1"]),
+			             jsonCommit(t, commit("Node2"), signers["Node2"]),
+			             protoCommit(t, otherDigest, signers["Node3"])),
+			valid: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.event.Check(3, "pbft", keys)
+			if test.valid && err != nil {
+				t.Errorf("event is refused: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("event is accepted")
+			}
+		})
+	}
+}
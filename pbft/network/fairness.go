@@ -0,0 +1,134 @@
+// Fair ordering of client requests, and detection of a primary that
+// delays the requests of some clients.
+//
+// Each replica keeps, for each request it received, when it first saw
+// the request, when the primary ordered it (when this replica got its
+// PRE-PREPARE), and how many requests it received later were ordered
+// in the meantime. A backup reports a request that has waited longer
+// than Params.FairnessThresholdMs while later requests were ordered:
+// the primary orders others, so it is not merely slow. The request
+// timer (see request.go) replaces a primary that stops ordering, and
+// is restarted at each execution; this catches one that keeps ordering
+// the requests of some clients while it delays or ignores the others.
+//
+// Reports are logged, counted by client, and served as JSON with the
+// most recent ones. With Params.FairnessViewChange, the backup also
+// votes for a view change.
+//
+//   curl http://localhost:1113/fairness
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Reports kept for /fairness.
+const maxDelayReports = 100
+
+// Request which waited too long while later requests were ordered.
+type DelayReport struct {
+	ClientID    string    `json:"clientID"`
+	Timestamp   int64     `json:"timestamp"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	WaitedMs    int64     `json:"waitedMs"`
+	OvertakenBy int       `json:"overtakenBy"` // later requests ordered first
+	ViewID      int64     `json:"viewID"`
+	Primary     string    `json:"primary"`
+}
+
+// Response of /fairness.
+type FairnessReport struct {
+	NodeID      string         `json:"nodeID"`
+	ThresholdMs int64          `json:"thresholdMs"`
+	Delayed     map[string]int `json:"delayed"` // key: clientID, value: delayed requests
+	Reports     []*DelayReport `json:"reports"` // most recent last
+}
+
+// Note the request is ordered by the primary, and report the requests
+// received before it which have waited too long.
+func (node *Node) markOrdered(reqMsg *consensus.RequestMsg) {
+	now := time.Now()
+	threshold := node.Params.fairnessThreshold()
+	key := fmt.Sprintf("%s/%d", reqMsg.ClientID, reqMsg.Timestamp)
+	viewID := node.View.ID
+	primary := node.getPrimaryInfoByID(viewID).NodeID
+
+	var reports []*DelayReport
+	node.pendingRequestsMutex.Lock()
+	ordered := node.pendingRequests[key]
+	if ordered == nil || !ordered.orderedAt.IsZero() {
+		node.pendingRequestsMutex.Unlock()
+		return
+	}
+	ordered.orderedAt = now
+
+	for _, pending := range node.pendingRequests {
+		if !pending.orderedAt.IsZero() || !pending.received.Before(ordered.received) {
+			continue
+		}
+		pending.overtakenBy++
+
+		if pending.reported || now.Sub(pending.received) < threshold {
+			continue
+		}
+		pending.reported = true
+		reports = append(reports, &DelayReport{
+			ClientID:    pending.request.ClientID,
+			Timestamp:   pending.request.Timestamp,
+			FirstSeen:   pending.received,
+			WaitedMs:    now.Sub(pending.received).Milliseconds(),
+			OvertakenBy: pending.overtakenBy,
+			ViewID:      viewID,
+			Primary:     primary,
+		})
+	}
+	node.pendingRequestsMutex.Unlock()
+
+	// The primary orders requests as it received them (see
+	// orderPendingRequests), so only backups judge the order.
+	if len(reports) == 0 || primary == node.MyInfo.NodeID {
+		return
+	}
+
+	node.delayReportsMutex.Lock()
+	for _, report := range reports {
+		log.Printf("request %s/%d waited %d ms while %d later requests were ordered by %s in view %d",
+		           report.ClientID, report.Timestamp, report.WaitedMs, report.OvertakenBy, report.Primary, report.ViewID)
+		node.delayedRequests[report.ClientID]++
+		node.delayReports = append(node.delayReports, report)
+	}
+	if len(node.delayReports) > maxDelayReports {
+		node.delayReports = node.delayReports[len(node.delayReports) - maxDelayReports:]
+	}
+	node.delayReportsMutex.Unlock()
+
+	// Off the path of the PRE-PREPARE message, as the request timer.
+	if node.Params.FairnessViewChange {
+		go node.suspectPrimary(fmt.Errorf("request %s/%d waited %d ms while later requests were ordered",
+		                                  reports[0].ClientID, reports[0].Timestamp, reports[0].WaitedMs))
+	}
+}
+
+// Serve the delayed requests reported by this node as JSON.
+func (server *Server) serveFairness(w http.ResponseWriter, r *http.Request) {
+	node := server.node
+	report := &FairnessReport{
+		NodeID:      node.MyInfo.NodeID,
+		ThresholdMs: node.Params.fairnessThreshold().Milliseconds(),
+		Delayed:     make(map[string]int),
+	}
+
+	node.delayReportsMutex.Lock()
+	for clientID, delayed := range node.delayedRequests {
+		report.Delayed[clientID] = delayed
+	}
+	report.Reports = append(report.Reports, node.delayReports...)
+	node.delayReportsMutex.Unlock()
+
+	writeJSON(w, report)
+}
@@ -0,0 +1,206 @@
+// HTTP/JSON gateway for request submission.
+//
+// POST /req with a signed RequestMsg as JSON body forwards the request
+// into consensus. With the "wait" query parameter (e.g., "wait=true"
+// or "wait=5s"), the response is delayed until this replica executes
+// the request, and carries its result. The result is that of this
+// replica only; clients that do not trust a single replica wait for
+// f+1 matching replies instead (see the client package). Requests
+// refused by admission control get 503 with Retry-After (see
+// admission.go).
+//
+//   curl -X POST -d @request.json 'http://localhost:1112/req?wait=true'
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Longest wait for the execution of a request, and the wait for
+// "wait=true".
+const MaxGatewayWait = time.Minute
+const DefaultGatewayWait = 10 * time.Second
+
+// Response of the gateway.
+type SubmitResult struct {
+	Accepted   bool   `json:"accepted"`
+	NodeID     string `json:"nodeID"`
+	Executed   bool   `json:"executed"`
+	SequenceID int64  `json:"sequenceID,omitempty"`
+	ViewID     int64  `json:"viewID,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (server *Server) serveRequest(w http.ResponseWriter, r *http.Request) {
+	result := &SubmitResult{NodeID: server.node.MyInfo.NodeID}
+
+	if r.Method != http.MethodPost {
+		result.Error = "POST a signed request"
+		writeJSONStatus(w, http.StatusMethodNotAllowed, result)
+		return
+	}
+
+	wait, err := gatewayWait(r.URL.Query().Get("wait"))
+	if err != nil {
+		result.Error = err.Error()
+		writeJSONStatus(w, http.StatusBadRequest, result)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		result.Error = err.Error()
+		writeJSONStatus(w, http.StatusRequestEntityTooLarge, result)
+		return
+	}
+	var request consensus.RequestMsg
+	if err := json.Unmarshal(body, &request); err != nil {
+		result.Error = err.Error()
+		writeJSONStatus(w, http.StatusBadRequest, result)
+		return
+	}
+
+	// The sequence number is assigned by the primary.
+	request.SequenceID = 0
+
+	// Replicas discard requests that are not signed by their
+	// client, so tell the sender now.
+	if err := server.node.verifyRequest(&request); err != nil {
+		result.Error = err.Error()
+		writeJSONStatus(w, http.StatusForbidden, result)
+		return
+	}
+
+	if err := server.node.admitRequest(&request); err != nil {
+		if busy, ok := err.(*BusyError); ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(busy.RetryAfter.Seconds()))))
+		}
+		result.Error = err.Error()
+		writeJSONStatus(w, http.StatusServiceUnavailable, result)
+		return
+	}
+
+	// Watch for the execution before submitting, so it is not missed.
+	var executed <-chan *MsgPair
+	if wait > 0 {
+		var cancel func()
+		executed, cancel = server.node.waitExecution(request.ClientID, request.Timestamp)
+		defer cancel()
+	}
+
+	server.node.Broadcast(&request, "/req")
+	result.Accepted = true
+
+	if wait == 0 {
+		writeJSONStatus(w, http.StatusAccepted, result)
+		return
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case pair := <-executed:
+		result.Executed = true
+		result.SequenceID = pair.committedMsg.SequenceID
+		result.ViewID = pair.replyMsg.ViewID
+		result.Result = pair.replyMsg.Result
+		writeJSONStatus(w, http.StatusOK, result)
+	case <-timer.C:
+		result.Error = fmt.Sprintf("not executed within %v", wait)
+		writeJSONStatus(w, http.StatusGatewayTimeout, result)
+	case <-r.Context().Done():
+	}
+}
+
+// Parse the "wait" query parameter: a duration, or a boolean.
+func gatewayWait(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	if wait, err := time.ParseDuration(value); err == nil {
+		if wait < 0 || wait > MaxGatewayWait {
+			return 0, fmt.Errorf("wait must be between 0 and %v", MaxGatewayWait)
+		}
+		return wait, nil
+	}
+
+	wait, err := strconv.ParseBool(value)
+	if err != nil {
+		return 0, fmt.Errorf("wait must be a duration or a boolean, not %q", value)
+	}
+	if !wait {
+		return 0, nil
+	}
+
+	return DefaultGatewayWait, nil
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Channel receiving the request of the client with the timestamp,
+// with its reply, once executed. It receives at once if the request
+// is the last one executed for the client.
+func (node *Node) waitExecution(clientID string, timestamp int64) (<-chan *MsgPair, func()) {
+	key := fmt.Sprintf("%s/%d", clientID, timestamp)
+	ch := make(chan *MsgPair, 1)
+
+	node.executionWaitersMutex.Lock()
+	node.executionWaiters[key] = append(node.executionWaiters[key], ch)
+	node.executionWaitersMutex.Unlock()
+
+	node.clientRequestsMutex.Lock()
+	if pair := node.lastReplies[clientID]; pair != nil && pair.replyMsg.Timestamp == timestamp {
+		ch <- pair
+	}
+	node.clientRequestsMutex.Unlock()
+
+	cancel := func() {
+		node.executionWaitersMutex.Lock()
+		defer node.executionWaitersMutex.Unlock()
+
+		waiters := node.executionWaiters[key]
+		for i, waiter := range waiters {
+			if waiter == ch {
+				waiters = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(waiters) == 0 {
+			delete(node.executionWaiters, key)
+		} else {
+			node.executionWaiters[key] = waiters
+		}
+	}
+
+	return ch, cancel
+}
+
+// Notify the waiters of the executed request.
+func (node *Node) notifyExecution(pair *MsgPair) {
+	key := fmt.Sprintf("%s/%d", pair.committedMsg.ClientID, pair.committedMsg.Timestamp)
+
+	node.executionWaitersMutex.Lock()
+	defer node.executionWaitersMutex.Unlock()
+
+	for _, ch := range node.executionWaiters[key] {
+		select {
+		case ch <- pair:
+		default:
+		}
+	}
+}
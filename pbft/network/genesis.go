@@ -0,0 +1,270 @@
+// Genesis document: the initial configuration of a cluster, signed by
+// all of its members. Every node verifies it at startup, so a node
+// cannot be started with a configuration the members did not agree on.
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Protocol parameters agreed in the genesis document.
+type Params struct {
+	// A checkpoint is taken every CheckPointPeriod requests.
+	CheckPointPeriod int64 `json:"checkPointPeriod"`
+
+	// Backups start a view change if a request is not committed
+	// within the deadline.
+	ConsensusDeadlineMs int64 `json:"consensusDeadlineMs"`
+
+	// Requests between the execution of a key rotation and the
+	// switch to the new key, and again until the old key expires
+	// (see keyring.go).
+	KeyGracePeriod int64 `json:"keyGracePeriod,omitempty"`
+
+	// Backups start a view change if a request they received is not
+	// executed within the timeout. Documents without it use the
+	// default.
+	RequestTimeoutMs int64 `json:"requestTimeoutMs,omitempty"`
+
+	// Requests a replica keeps until they are executed, beyond which
+	// it refuses new ones (see admission.go). Documents without it
+	// use the default.
+	MaxPendingRequests int `json:"maxPendingRequests,omitempty"`
+
+	// Requests per second a replica admits from each client, and from
+	// all clients together, or 0 for no limit.
+	ClientRateLimit float64 `json:"clientRateLimit,omitempty"`
+	RateLimit       float64 `json:"rateLimit,omitempty"`
+
+	// Replicas report a request that waits longer than the threshold
+	// while later requests are ordered, and vote for a view change if
+	// FairnessViewChange is set (see fairness.go). Documents without
+	// the threshold use the default.
+	FairnessThresholdMs int64 `json:"fairnessThresholdMs,omitempty"`
+	FairnessViewChange  bool  `json:"fairnessViewChange,omitempty"`
+}
+
+var DefaultParams = Params{
+	CheckPointPeriod:    5,
+	ConsensusDeadlineMs: 100,
+	KeyGracePeriod:      10,
+	RequestTimeoutMs:    2000,
+	MaxPendingRequests:  1024,
+	FairnessThresholdMs: 1000,
+}
+
+func (params Params) consensusDeadline() time.Duration {
+	return time.Duration(params.ConsensusDeadlineMs) * time.Millisecond
+}
+
+func (params Params) requestTimeout() time.Duration {
+	if params.RequestTimeoutMs == 0 {
+		return time.Duration(DefaultParams.RequestTimeoutMs) * time.Millisecond
+	}
+
+	return time.Duration(params.RequestTimeoutMs) * time.Millisecond
+}
+
+func (params Params) maxPendingRequests() int {
+	if params.MaxPendingRequests == 0 {
+		return DefaultParams.MaxPendingRequests
+	}
+
+	return params.MaxPendingRequests
+}
+
+func (params Params) fairnessThreshold() time.Duration {
+	if params.FairnessThresholdMs == 0 {
+		return time.Duration(DefaultParams.FairnessThresholdMs) * time.Millisecond
+	}
+
+	return time.Duration(params.FairnessThresholdMs) * time.Millisecond
+}
+
+type GenesisMember struct {
+	NodeID  string `json:"nodeID"`
+	Url     string `json:"url"`
+	GrpcUrl string `json:"grpcUrl,omitempty"`
+	PubKey  string `json:"pubKey"` // PEM encoded
+}
+
+type Genesis struct {
+	ClusterID       string           `json:"clusterID"`
+	ProtocolVersion uint32           `json:"protocolVersion"`
+	F               int              `json:"f"`
+	InitialView     int64            `json:"initialView"`
+	Params          Params           `json:"params"`
+	Members         []*GenesisMember `json:"members"`
+
+	// Signatures of the members over all the other fields.
+	// key: nodeID
+	Signatures map[string][]byte `json:"signatures,omitempty"`
+}
+
+// NewGenesis creates an unsigned genesis document for the members,
+// each with their public key in PEM.
+func NewGenesis(clusterID string, initialView int64, params Params, members []*GenesisMember) *Genesis {
+	return &Genesis{
+		ClusterID:       clusterID,
+		ProtocolVersion: consensus.ProtocolVersion,
+		F:               (len(members) - 1) / 3,
+		InitialView:     initialView,
+		Params:          params,
+		Members:         members,
+	}
+}
+
+func LoadGenesis(path string) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var genesis Genesis
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		return nil, fmt.Errorf("genesis %s: %v", path, err)
+	}
+
+	return &genesis, nil
+}
+
+func (genesis *Genesis) Save(path string) error {
+	data, err := json.MarshalIndent(genesis, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func (genesis *Genesis) signingBytes() ([]byte, error) {
+	unsigned := *genesis
+	unsigned.Signatures = nil
+
+	payload, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	return consensus.SigningBytes(genesis.ProtocolVersion, genesis.ClusterID, "/genesis", "", payload), nil
+}
+
+// Digest identifies the configuration, regardless of the signatures.
+func (genesis *Genesis) Digest() string {
+	data, err := genesis.signingBytes()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return consensus.Hash(data)
+}
+
+// Sign adds the signature of the member.
+func (genesis *Genesis) Sign(nodeID string, signer consensus.Signer) error {
+	if genesis.member(nodeID) == nil {
+		return fmt.Errorf("%s is not a member of the genesis", nodeID)
+	}
+
+	data, err := genesis.signingBytes()
+	if err != nil {
+		return err
+	}
+	signature, err := signer.Sign(data)
+	if err != nil {
+		return err
+	}
+
+	if genesis.Signatures == nil {
+		genesis.Signatures = make(map[string][]byte)
+	}
+	genesis.Signatures[nodeID] = signature
+
+	return nil
+}
+
+func (genesis *Genesis) member(nodeID string) *GenesisMember {
+	for _, member := range genesis.Members {
+		if member.NodeID == nodeID {
+			return member
+		}
+	}
+
+	return nil
+}
+
+// Verify checks the configuration is consistent and signed by all the
+// members, and returns the node table with the public keys of the
+// members.
+func (genesis *Genesis) Verify() ([]*NodeInfo, error) {
+	if genesis.ProtocolVersion != consensus.ProtocolVersion {
+		return nil, fmt.Errorf("protocol version %d is not supported (%d)",
+		                       genesis.ProtocolVersion, consensus.ProtocolVersion)
+	}
+	if genesis.ClusterID == "" {
+		return nil, errors.New("no cluster ID")
+	}
+	if len(genesis.Members) == 0 {
+		return nil, errors.New("no members")
+	}
+
+	// f is fixed by the number of members: n = 3f + 1 at least,
+	// and quorums are counted with f = (n - 1) / 3.
+	if f := (len(genesis.Members) - 1) / 3; genesis.F != f {
+		return nil, fmt.Errorf("f is %d, but %d members tolerate f = %d", genesis.F, len(genesis.Members), f)
+	}
+	if genesis.InitialView < 0 {
+		return nil, fmt.Errorf("initial view %d is negative", genesis.InitialView)
+	}
+	if genesis.Params.CheckPointPeriod <= 0 || genesis.Params.ConsensusDeadlineMs <= 0 ||
+	   genesis.Params.KeyGracePeriod < 0 || genesis.Params.RequestTimeoutMs < 0 ||
+	   genesis.Params.MaxPendingRequests < 0 || genesis.Params.ClientRateLimit < 0 || genesis.Params.RateLimit < 0 ||
+	   genesis.Params.FairnessThresholdMs < 0 {
+		return nil, fmt.Errorf("invalid parameters %+v", genesis.Params)
+	}
+
+	nodeTable := make([]*NodeInfo, 0, len(genesis.Members))
+	for _, member := range genesis.Members {
+		if findNodeInfo(nodeTable, member.NodeID) != nil {
+			return nil, fmt.Errorf("member %s is listed twice", member.NodeID)
+		}
+
+		pubKey, err := consensus.ParsePublicKeyPEM([]byte(member.PubKey))
+		if err != nil {
+			return nil, fmt.Errorf("public key of %s: %v", member.NodeID, err)
+		}
+
+		nodeTable = append(nodeTable, &NodeInfo{
+			NodeID:  member.NodeID,
+			Url:     member.Url,
+			GrpcUrl: member.GrpcUrl,
+			PubKey:  pubKey,
+		})
+	}
+
+	data, err := genesis.signingBytes()
+	if err != nil {
+		return nil, err
+	}
+	for nodeID := range genesis.Signatures {
+		if findNodeInfo(nodeTable, nodeID) == nil {
+			return nil, fmt.Errorf("signed by %s, which is not a member", nodeID)
+		}
+	}
+	for _, nodeInfo := range nodeTable {
+		signature, ok := genesis.Signatures[nodeInfo.NodeID]
+		if !ok {
+			return nil, fmt.Errorf("not signed by %s", nodeInfo.NodeID)
+		}
+		if !nodeInfo.PubKey.Verify(signature, data) {
+			return nil, fmt.Errorf("invalid signature of %s", nodeInfo.NodeID)
+		}
+	}
+
+	return nodeTable, nil
+}
@@ -0,0 +1,487 @@
+// gRPC transport. Each node opens one bidirectional stream to every
+// node (including itself), sends its consensus messages on it and
+// receives cumulative acknowledgements back. Unacknowledged messages
+// are resent in order after the stream is opened again.
+//
+// Streams are authenticated as replicas (see auth.go) and carry the
+// messages of that replica only, so the epoch and sequence numbers,
+// which are not signed, come from the sender itself.
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/pbftpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type grpcTransport struct {
+	myInfo    *NodeInfo
+	clusterID string
+	signer    consensus.Signer
+	epoch     int64
+	peers     []*grpcPeer
+}
+
+type grpcPeer struct {
+	nodeInfo *NodeInfo
+	status   *PeerTable
+
+	mutex   sync.Mutex
+	nextSeq uint64
+	unacked []*pbftpb.Envelope // in sequence number order
+	bytes   int
+
+	// Signals the sender that new messages are queued.
+	notify chan struct{}
+}
+
+func newGrpcTransport(myInfo *NodeInfo, nodeTable []*NodeInfo, clusterID string, signer consensus.Signer, status *PeerTable) *grpcTransport {
+	t := &grpcTransport{
+		myInfo:    myInfo,
+		clusterID: clusterID,
+		signer:    signer,
+		epoch:     time.Now().UnixNano(),
+	}
+
+	for _, nodeInfo := range nodeTable {
+		t.peers = append(t.peers, &grpcPeer{
+			nodeInfo: nodeInfo,
+			status:   status,
+			nextSeq:  1,
+			notify:   make(chan struct{}, 1),
+		})
+	}
+
+	return t
+}
+
+// Signed part of an envelope. The message is encoded deterministically
+// as the payload; the message type is that of the message.
+func signingBytes(env *pbftpb.Envelope) ([]byte, error) {
+	msgType, _, err := msgOfEnvelope(env)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := envelopePayload(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return consensus.SigningBytes(env.GetVersion(), env.GetClusterId(), msgType, env.GetSender(), payload), nil
+}
+
+// Envelope with the message only, as signed.
+func envelopePayload(env *pbftpb.Envelope) ([]byte, error) {
+	return proto.MarshalOptions{Deterministic: true}.Marshal(&pbftpb.Envelope{Msg: env.GetMsg()})
+}
+
+// COMMIT message in the envelope as its sender signed it.
+func signedCommitOf(env *pbftpb.Envelope) (*SignedCommit, error) {
+	payload, err := envelopePayload(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedCommit{
+		Encoding: EncodingProto,
+		Envelope: &consensus.SignatureMsg{
+			Version:       env.GetVersion(),
+			ClusterID:     env.GetClusterId(),
+			MsgType:       "/commit",
+			NodeID:        env.GetSender(),
+			MarshalledMsg: payload,
+			Signature:     env.GetSignature(),
+		},
+	}, nil
+}
+
+func (t *grpcTransport) Broadcast(msgType string, msg interface{}) error {
+	env, err := t.signedEnvelope(msgType, msg)
+	if err != nil {
+		return err
+	}
+
+	for _, peer := range t.peers {
+		peerEnv := proto.Clone(env).(*pbftpb.Envelope)
+		peerEnv.Epoch = t.epoch
+		peer.enqueue(peerEnv)
+	}
+
+	return nil
+}
+
+func (t *grpcTransport) Send(nodeID string, msgType string, msg interface{}) error {
+	for _, peer := range t.peers {
+		if peer.nodeInfo.NodeID != nodeID {
+			continue
+		}
+
+		env, err := t.signedEnvelope(msgType, msg)
+		if err != nil {
+			return err
+		}
+		env.Epoch = t.epoch
+		peer.enqueue(env)
+
+		return nil
+	}
+
+	return fmt.Errorf("no node %s to send %s message to", nodeID, msgType)
+}
+
+func (t *grpcTransport) signedEnvelope(msgType string, msg interface{}) (*pbftpb.Envelope, error) {
+	env, err := envelopeOf(msgType, msg)
+	if err != nil {
+		return nil, err
+	}
+	env.Version = consensus.ProtocolVersion
+	env.ClusterId = t.clusterID
+	env.Sender = t.myInfo.NodeID
+
+	data, err := signingBytes(env)
+	if err != nil {
+		return nil, err
+	}
+	env.Signature, err = t.signer.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+func (peer *grpcPeer) enqueue(env *pbftpb.Envelope) {
+	peer.mutex.Lock()
+	env.Seq = peer.nextSeq
+	peer.nextSeq++
+	peer.unacked = append(peer.unacked, env)
+	peer.bytes += proto.Size(env)
+
+	// Bound memory for peers that stay unreachable by
+	// dropping the oldest messages.
+	dropped := 0
+	for len(peer.unacked) > MaxUnackedMsgs || peer.bytes > MaxUnackedBytes {
+		peer.bytes -= proto.Size(peer.unacked[0])
+		peer.unacked[0] = nil
+		peer.unacked = peer.unacked[1:]
+		dropped++
+	}
+	peer.mutex.Unlock()
+
+	if dropped > 0 {
+		log.Printf("outbox for %s is full: %d unacknowledged messages dropped", peer.nodeInfo.NodeID, dropped)
+	}
+
+	select {
+	case peer.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (peer *grpcPeer) ack(seq uint64) {
+	peer.mutex.Lock()
+	n := 0
+	for n < len(peer.unacked) && peer.unacked[n].GetSeq() <= seq {
+		peer.bytes -= proto.Size(peer.unacked[n])
+		peer.unacked[n] = nil
+		n++
+	}
+	peer.unacked = peer.unacked[n:]
+	peer.mutex.Unlock()
+}
+
+// Messages after the given sequence number which are not acknowledged.
+func (peer *grpcPeer) pending(after uint64) []*pbftpb.Envelope {
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+
+	envs := make([]*pbftpb.Envelope, 0)
+	for _, env := range peer.unacked {
+		if env.GetSeq() > after {
+			envs = append(envs, env)
+		}
+	}
+
+	return envs
+}
+
+// Start a sender for every peer, using mutual TLS if config is not
+// nil, or else signed metadata.
+func (t *grpcTransport) start(config *TLSConfig) {
+	for _, peer := range t.peers {
+		creds := insecure.NewCredentials()
+		var handshake func(nonce string) (metadata.MD, error)
+		if config != nil {
+			creds = credentials.NewTLS(config.DialConfig(peer.nodeInfo.NodeID))
+		} else {
+			nodeID := peer.nodeInfo.NodeID
+			handshake = func(nonce string) (metadata.MD, error) {
+				return CallMetadata(t.clusterID, t.myInfo.NodeID, nodeID, nonce, t.signer)
+			}
+		}
+		go peer.run(creds, handshake)
+	}
+}
+
+// Keep a stream to the peer, and open it again with jittered
+// exponential backoff whenever it cannot be opened or is lost.
+func (peer *grpcPeer) run(creds credentials.TransportCredentials, handshake func(nonce string) (metadata.MD, error)) {
+	var backoff dialBackoff
+	nodeID := peer.nodeInfo.NodeID
+
+	conn, err := grpc.NewClient(peer.nodeInfo.GrpcUrl, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		log.Printf("gRPC client for %s: %v", nodeID, err)
+		return
+	}
+	defer conn.Close()
+
+	client := pbftpb.NewReplicaClient(conn)
+	nonces := pbftpb.NewHandshakeClient(conn)
+
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := openStream(ctx, client, nonces, handshake)
+		if err != nil {
+			peer.status.setState(nodeID, PeerConnecting, err)
+		} else {
+			peer.status.setState(nodeID, PeerConnected, nil)
+			backoff.reset()
+
+			err = peer.send(stream)
+			peer.status.setState(nodeID, PeerDisconnected, err)
+		}
+		cancel()
+
+		time.Sleep(backoff.next())
+	}
+}
+
+// Open a stream, authenticated by the handshake without TLS.
+func openStream(ctx context.Context, client pbftpb.ReplicaClient, nonces pbftpb.HandshakeClient, handshake func(nonce string) (metadata.MD, error)) (pbftpb.Replica_StreamClient, error) {
+	if handshake != nil {
+		reply, err := nonces.Nonce(ctx, &pbftpb.NonceRequest{}, grpc.WaitForReady(false))
+		if err != nil {
+			return nil, err
+		}
+		md, err := handshake(reply.GetNonce())
+		if err != nil {
+			return nil, err
+		}
+		ctx = metadata.NewOutgoingContext(ctx, md)
+	}
+
+	return client.Stream(ctx, grpc.WaitForReady(false))
+}
+
+// Send queued messages on the stream until it fails.
+func (peer *grpcPeer) send(stream pbftpb.Replica_StreamClient) error {
+	errCh := make(chan error, 1)
+
+	// Receive acknowledgements.
+	go func() {
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			peer.ack(ack.GetSeq())
+		}
+	}()
+
+	// Resend everything not acknowledged on the new stream.
+	var sent uint64 = 0
+	ticker := time.NewTicker(RetransmitTimeout)
+	defer ticker.Stop()
+
+	for {
+		for _, env := range peer.pending(sent) {
+			if err := stream.Send(env); err != nil {
+				return err
+			}
+			sent = env.GetSeq()
+		}
+
+		select {
+		case <-peer.notify:
+		case <-ticker.C:
+		case err := <-errCh:
+			return err
+		}
+	}
+}
+
+// Receiving side of the gRPC transport, the Submit RPC for clients, and
+// the nonces for their handshakes.
+type grpcServer struct {
+	pbftpb.UnimplementedReplicaServer
+	pbftpb.UnimplementedClientServer
+	pbftpb.UnimplementedHandshakeServer
+
+	node *Node
+	tls  *TLSConfig
+
+	// Last message delivered from each node. key: nodeID
+	cursorsMutex sync.Mutex
+	cursors      map[string]*peerCursor
+}
+
+func (server *Server) startGrpcServer() {
+	lis, err := net.Listen("tcp", server.node.MyInfo.GrpcUrl)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(maxMessageSize)}
+	if server.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(server.tls.ServerConfig())))
+	}
+	s := grpc.NewServer(opts...)
+	gs := &grpcServer{node: server.node, tls: server.tls, cursors: make(map[string]*peerCursor)}
+	pbftpb.RegisterReplicaServer(s, gs)
+	pbftpb.RegisterClientServer(s, gs)
+	pbftpb.RegisterHandshakeServer(s, gs)
+
+	log.Printf("gRPC server will be started at %s...\n", server.node.MyInfo.GrpcUrl)
+	if err := s.Serve(lis); err != nil {
+		log.Println(err)
+	}
+}
+
+// Nonce for the signed metadata of a call (see auth.go).
+func (gs *grpcServer) Nonce(ctx context.Context, req *pbftpb.NonceRequest) (*pbftpb.NonceReply, error) {
+	return &pbftpb.NonceReply{Nonce: gs.node.issueNonce(time.Now())}, nil
+}
+
+func (gs *grpcServer) Stream(stream pbftpb.Replica_StreamServer) error {
+	identity, err := authenticateCall(stream.Context(), gs.tls, gs.node)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	nodeInfo := findNodeInfo(gs.node.NodeTable, identity.NodeID)
+	if nodeInfo == nil {
+		return status.Errorf(codes.PermissionDenied, "%s may not open a replica stream", identity)
+	}
+
+	for {
+		env, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		if env.GetSender() != nodeInfo.NodeID {
+			return status.Errorf(codes.PermissionDenied, "stream of %s carries a message of %q", nodeInfo.NodeID, env.GetSender())
+		}
+
+		// Verify the message before it moves the cursor of its
+		// sender, so others cannot make messages of the sender to
+		// be skipped or delivered twice. Invalid messages are
+		// acknowledged, as resending them does not help.
+		msgType, msg, err := gs.openEnvelope(nodeInfo, env)
+		if err != nil {
+			log.Println(err)
+		} else if gs.advanceCursor(nodeInfo.NodeID, env) {
+			if msgType == "/commit" {
+				if commit, err := signedCommitOf(env); err == nil {
+					gs.node.keepSignedCommit(nodeInfo.NodeID, msg, commit)
+				}
+			}
+			if err = gs.node.deliverMsg(msgType, nodeInfo.NodeID, msg); err != nil {
+				log.Println(err)
+			}
+		}
+
+		if err := stream.Send(&pbftpb.Ack{Epoch: env.GetEpoch(), Seq: env.GetSeq()}); err != nil {
+			return err
+		}
+	}
+}
+
+// Skip retransmitted messages, and start over when the sender has
+// restarted. Returns false for a message delivered already.
+func (gs *grpcServer) advanceCursor(nodeID string, env *pbftpb.Envelope) bool {
+	gs.cursorsMutex.Lock()
+	defer gs.cursorsMutex.Unlock()
+
+	cursor, ok := gs.cursors[nodeID]
+	if !ok || cursor.epoch != env.GetEpoch() {
+		cursor = &peerCursor{epoch: env.GetEpoch()}
+		gs.cursors[nodeID] = cursor
+	}
+	if env.GetSeq() <= cursor.seq {
+		return false
+	}
+	cursor.seq = env.GetSeq()
+
+	return true
+}
+
+// Check the version, cluster and signature of the envelope from the
+// given node, and get its message.
+func (gs *grpcServer) openEnvelope(nodeInfo *NodeInfo, env *pbftpb.Envelope) (string, interface{}, error) {
+	if env.GetVersion() != consensus.ProtocolVersion {
+		return "", nil, fmt.Errorf("message from %s has protocol version %d", nodeInfo.NodeID, env.GetVersion())
+	}
+	if env.GetClusterId() != gs.node.ClusterID {
+		return "", nil, fmt.Errorf("message from %s is for cluster %q", nodeInfo.NodeID, env.GetClusterId())
+	}
+
+	data, err := signingBytes(env)
+	if err != nil {
+		return "", nil, err
+	}
+	if !gs.node.Keys.Verifier(nodeInfo.NodeID).Verify(env.GetSignature(), data) {
+		return "", nil, fmt.Errorf("invalid signature on message from %s", nodeInfo.NodeID)
+	}
+
+	return msgOfEnvelope(env)
+}
+
+// Submit broadcasts the request to all nodes, the same way
+// as the requests submitted to the gateway. Callers must be replicas or
+// known clients; a caller may submit the requests of other clients,
+// e.g., as a gateway, as requests are signed by their clients.
+func (gs *grpcServer) Submit(ctx context.Context, req *pbftpb.RequestMsg) (*pbftpb.SubmitReply, error) {
+	if _, err := authenticateCall(ctx, gs.tls, gs.node); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	request := requestFromPb(req)
+
+	// The sequence number is assigned by the primary.
+	request.SequenceID = 0
+
+	// Rate limits are kept for known clients only.
+	if err := gs.node.verifyRequest(request); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if err := gs.node.admitRequest(request); err != nil {
+		if busy, ok := err.(*BusyError); ok {
+			grpc.SetHeader(ctx, metadata.Pairs(RetryAfterHeader, strconv.FormatInt(busy.RetryAfter.Milliseconds(), 10)))
+		}
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	gs.node.Broadcast(request, "/req")
+
+	return &pbftpb.SubmitReply{Accepted: true, NodeId: gs.node.MyInfo.NodeID}, nil
+}
@@ -4,50 +4,252 @@
 
 package network
 
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Message from a subscribed client, with the client it came from.
+type clientMsg struct {
+	client *Client
+	msg    *PeerMsg
+}
+
 // Hub maintains the set of active clients and broadcasts messages to the
 // clients.
+//
+// Messages for cluster members are kept in a per-peer outbox until the
+// peer acknowledges them, so they survive a full send queue or a lost
+// connection and are retransmitted in order. Clients get their replies
+// on a best-effort basis.
 type Hub struct {
 	// Registered clients.
 	clients map[*Client]bool
 
-	// Inbound messages from the clients.
-	broadcast chan []byte
+	// Unacknowledged messages for each peer. key: nodeID
+	outboxes map[string]*outbox
+
+	// Sequence numbers restart when the node restarts, so they are
+	// only meaningful together with the epoch of the hub.
+	epoch int64
+
+	// Messages published by this node.
+	broadcast chan *PeerMsg
 
 	// Register requests from the clients.
 	register chan *Client
 
 	// Unregister requests from clients.
 	unregister chan *Client
+
+	// HELLO and ACK messages from subscribed peers.
+	control chan *clientMsg
+}
+
+type outMsg struct {
+	seq    uint64
+	frame  []byte // marshalled PeerMsg carrying epoch and seq
+	sentAt time.Time
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		broadcast:  make(chan []byte),
+type outbox struct {
+	nodeID  string
+	client  *Client // nil while the peer is not connected
+	nextSeq uint64
+
+	// Messages not acknowledged yet, in sequence number order.
+	// msgs[:unsent] are sent in the current round.
+	msgs   []*outMsg
+	unsent int
+	bytes  int
+}
+
+func NewHub(nodeTable []*NodeInfo) *Hub {
+	hub := &Hub{
+		broadcast:  make(chan *PeerMsg),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		control:    make(chan *clientMsg),
 		clients:    make(map[*Client]bool),
+		outboxes:   make(map[string]*outbox),
+		epoch:      time.Now().UnixNano(),
+	}
+
+	for _, nodeInfo := range nodeTable {
+		hub.outboxes[nodeInfo.NodeID] = &outbox{nodeID: nodeInfo.NodeID, nextSeq: 1}
 	}
+
+	return hub
 }
 
 func (h *Hub) run() {
+	ticker := time.NewTicker(RetransmitCheckPeriod)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case client := <-h.register:
 			h.clients[client] = true
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
+				if ob := h.outboxes[client.nodeID]; ob != nil && ob.client == client {
+					ob.client = nil
+				}
 				delete(h.clients, client)
 				close(client.send)
 			}
+		case cmsg := <-h.control:
+			h.handleControl(cmsg.client, cmsg.msg)
 		case message := <-h.broadcast:
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+			h.enqueue(message)
+		case <-ticker.C:
+			h.retransmit()
+		}
+	}
+}
+
+func (h *Hub) handleControl(client *Client, msg *PeerMsg) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+
+	switch msg.Type {
+	case "/hello":
+		// Only the authenticated replica gets its outbox.
+		if msg.NodeID != client.identity.NodeID {
+			log.Printf("%s says hello as %s", client.identity, msg.NodeID)
+			return
+		}
+		ob := h.outboxes[msg.NodeID]
+		if ob == nil {
+			return
+		}
+
+		// The latest connection of the peer replaces the old one.
+		client.nodeID = msg.NodeID
+		ob.client = client
+
+		if msg.Epoch == h.epoch {
+			ob.ack(msg.Seq)
+		}
+
+		// Resend everything the peer has not acknowledged.
+		ob.unsent = 0
+		ob.pump()
+	case "/ack":
+		ob := h.outboxes[client.nodeID]
+		if ob == nil || ob.client != client || msg.Epoch != h.epoch {
+			return
+		}
+
+		ob.ack(msg.Seq)
+		ob.pump()
+	}
+}
+
+func (h *Hub) enqueue(message *PeerMsg) {
+	// Best effort for clients, which get the messages for them
+	// only, i.e., the replies to their own requests.
+	var frame []byte
+	for client := range h.clients {
+		if client.identity.ClientID == "" || message.to != client.identity.ClientID {
+			continue
+		}
+
+		if frame == nil {
+			frame, _ = json.Marshal(message)
+		}
+
+		select {
+		case client.send <- frame:
+		default:
+			// Drop the message, not the client.
+		}
+	}
+
+	for _, ob := range h.outboxes {
+		if message.to != "" && message.to != ob.nodeID {
+			continue
+		}
+
+		msg := *message
+		msg.Epoch = h.epoch
+		msg.Seq = ob.nextSeq
+		data, err := json.Marshal(&msg)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		ob.msgs = append(ob.msgs, &outMsg{seq: ob.nextSeq, frame: data})
+		ob.bytes += len(data)
+		ob.nextSeq++
+
+		// Bound memory for peers that stay unreachable by
+		// dropping the oldest messages.
+		dropped := 0
+		for len(ob.msgs) > MaxUnackedMsgs || ob.bytes > MaxUnackedBytes {
+			ob.bytes -= len(ob.msgs[0].frame)
+			ob.msgs[0] = nil
+			ob.msgs = ob.msgs[1:]
+			if ob.unsent > 0 {
+				ob.unsent--
 			}
+			dropped++
+		}
+		if dropped > 0 {
+			log.Printf("outbox for %s is full: %d unacknowledged messages dropped", ob.nodeID, dropped)
+		}
+
+		ob.pump()
+	}
+}
+
+// Go back to the first unacknowledged message of each peer which has
+// not acknowledged it in time.
+func (h *Hub) retransmit() {
+	now := time.Now()
+
+	for _, ob := range h.outboxes {
+		if ob.client == nil || len(ob.msgs) == 0 {
+			continue
+		}
+
+		if ob.unsent > 0 && now.Sub(ob.msgs[0].sentAt) > RetransmitTimeout {
+			ob.unsent = 0
+		}
+		ob.pump()
+	}
+}
+
+// Remove the messages acknowledged by the peer.
+func (ob *outbox) ack(seq uint64) {
+	n := 0
+	for n < len(ob.msgs) && ob.msgs[n].seq <= seq {
+		ob.bytes -= len(ob.msgs[n].frame)
+		ob.msgs[n] = nil
+		n++
+	}
+
+	ob.msgs = ob.msgs[n:]
+	ob.unsent -= n
+	if ob.unsent < 0 {
+		ob.unsent = 0
+	}
+}
+
+// Send messages in order while the send queue of the peer has room.
+func (ob *outbox) pump() {
+	for ob.client != nil && ob.unsent < len(ob.msgs) {
+		msg := ob.msgs[ob.unsent]
+
+		select {
+		case ob.client.send <- msg.frame:
+			msg.sentAt = time.Now()
+			ob.unsent++
+		default:
+			return
 		}
 	}
 }
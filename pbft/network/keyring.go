@@ -0,0 +1,287 @@
+// Key rotation of replicas without downtime.
+//
+// A replica rotates its key with a request of operation OpRotateKey,
+// sent under its node ID and carrying a KeyRotation signed by both its
+// current and its new key. The request is ordered like any other, so
+// all replicas switch keys at the same point of the committed log.
+// For a rotation executed at sequence number s, with grace period G:
+//
+//   from s        both keys are accepted;
+//   from s + G    the replica signs with its new key;
+//   from s + 2G   the old key is no longer accepted.
+//
+// Replicas lagging less than G requests behind thus still accept the
+// messages of the rotating replica, before and after it switches.
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Operation of a key rotation request.
+const OpRotateKey = "rotateKey"
+
+type KeyRotation struct {
+	NodeID       string `json:"nodeID"`
+	NewPubKey    string `json:"newPubKey"` // PEM encoded
+	Timestamp    int64  `json:"timestamp"` // same timestamp value as RequestMsg
+	OldSignature []byte `json:"oldSignature,omitempty"`
+	NewSignature []byte `json:"newSignature,omitempty"`
+}
+
+func (rotation *KeyRotation) signingBytes(clusterID string) []byte {
+	payload, _ := json.Marshal(&KeyRotation{
+		NodeID:    rotation.NodeID,
+		NewPubKey: rotation.NewPubKey,
+		Timestamp: rotation.Timestamp,
+	})
+
+	return consensus.SigningBytes(consensus.ProtocolVersion, clusterID, "/rotatekey", rotation.NodeID, payload)
+}
+
+// Sign fills the signatures of the old and the new key.
+func (rotation *KeyRotation) Sign(clusterID string, oldSigner consensus.Signer, newSigner consensus.Signer) error {
+	var err error
+	data := rotation.signingBytes(clusterID)
+
+	if rotation.OldSignature, err = oldSigner.Sign(data); err != nil {
+		return err
+	}
+	if rotation.NewSignature, err = newSigner.Sign(data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Public keys of the replicas, as changed by the key rotations
+// executed so far.
+type KeyRing struct {
+	mutex sync.RWMutex
+	keys  map[string]*replicaKeys // key: nodeID
+}
+
+type replicaKeys struct {
+	current  consensus.Verifier
+	next     consensus.Verifier // becomes current at switchAt
+	previous consensus.Verifier // accepted until expireAt
+	switchAt int64
+	expireAt int64
+}
+
+func NewKeyRing(nodeTable []*NodeInfo) *KeyRing {
+	ring := &KeyRing{keys: make(map[string]*replicaKeys)}
+	for _, nodeInfo := range nodeTable {
+		ring.keys[nodeInfo.NodeID] = &replicaKeys{current: nodeInfo.PubKey}
+	}
+
+	return ring
+}
+
+// Verifier accepts signatures of every key of the node that is valid
+// now, or returns nil for an unknown node.
+func (ring *KeyRing) Verifier(nodeID string) consensus.Verifier {
+	ring.mutex.RLock()
+	defer ring.mutex.RUnlock()
+
+	keys, ok := ring.keys[nodeID]
+	if !ok {
+		return nil
+	}
+
+	verifiers := anyVerifier{keys.current}
+	if keys.next != nil {
+		verifiers = append(verifiers, keys.next)
+	}
+	if keys.previous != nil {
+		verifiers = append(verifiers, keys.previous)
+	}
+
+	return verifiers
+}
+
+// Check the rotation and schedule it for the given sequence number.
+func (ring *KeyRing) rotate(clusterID string, rotation *KeyRotation, sequenceID int64, gracePeriod int64) error {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	keys, ok := ring.keys[rotation.NodeID]
+	if !ok {
+		return fmt.Errorf("%s is not a replica", rotation.NodeID)
+	}
+	if keys.next != nil {
+		return fmt.Errorf("key rotation of %s is in progress until sequence %d", rotation.NodeID, keys.switchAt)
+	}
+
+	newKey, err := consensus.ParsePublicKeyPEM([]byte(rotation.NewPubKey))
+	if err != nil {
+		return fmt.Errorf("new key of %s: %v", rotation.NodeID, err)
+	}
+
+	data := rotation.signingBytes(clusterID)
+	if !keys.current.Verify(rotation.OldSignature, data) {
+		return fmt.Errorf("key rotation of %s is not signed by its current key", rotation.NodeID)
+	}
+	if !newKey.Verify(rotation.NewSignature, data) {
+		return fmt.Errorf("key rotation of %s is not signed by its new key", rotation.NodeID)
+	}
+
+	keys.next = newKey
+	keys.switchAt = sequenceID + gracePeriod
+	keys.expireAt = sequenceID + 2 * gracePeriod
+
+	return nil
+}
+
+// Switch and expire keys once the given sequence number is executed,
+// and return the nodes which switched to their new key.
+func (ring *KeyRing) advance(sequenceID int64) []string {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	var switched []string
+	for nodeID, keys := range ring.keys {
+		if keys.next != nil && sequenceID >= keys.switchAt {
+			keys.previous = keys.current
+			keys.current = keys.next
+			keys.next = nil
+			switched = append(switched, nodeID)
+		}
+		if keys.previous != nil && sequenceID >= keys.expireAt {
+			keys.previous = nil
+			fmt.Printf("Old key of %s expired at sequence %d\n", nodeID, sequenceID)
+		}
+	}
+
+	return switched
+}
+
+// Signatures of any of the keys are accepted.
+type anyVerifier []consensus.Verifier
+
+func (verifiers anyVerifier) Verify(signature []byte, data []byte) bool {
+	for _, verifier := range verifiers {
+		if verifier.Verify(signature, data) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Signer of this node, replaced when the node switches to its new key.
+type nodeSigner struct {
+	mutex  sync.RWMutex
+	signer consensus.Signer
+	next   consensus.Signer // private key of a requested rotation
+}
+
+func (s *nodeSigner) Sign(data []byte) ([]byte, error) {
+	s.mutex.RLock()
+	signer := s.signer
+	s.mutex.RUnlock()
+
+	return signer.Sign(data)
+}
+
+func (s *nodeSigner) Verifier() consensus.Verifier {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.signer.Verifier()
+}
+
+// Create the request rotating the key of this node to that of the
+// next signer, and keep the next signer until the rotation executes.
+func (node *Node) newKeyRotationRequest(next consensus.Signer, timestamp int64) (*consensus.RequestMsg, error) {
+	pubKey, err := consensus.MarshalVerifierPEM(next.Verifier())
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := &KeyRotation{
+		NodeID:    node.MyInfo.NodeID,
+		NewPubKey: string(pubKey),
+		Timestamp: timestamp,
+	}
+	if err := rotation.Sign(node.ClusterID, node.Signer, next); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(rotation)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &consensus.RequestMsg{
+		Timestamp: timestamp,
+		ClientID:  node.MyInfo.NodeID,
+		Operation: OpRotateKey,
+		Data:      string(data),
+	}
+	if err := request.Sign(node.ClusterID, node.Signer); err != nil {
+		return nil, err
+	}
+
+	node.signer.mutex.Lock()
+	node.signer.next = next
+	node.signer.mutex.Unlock()
+
+	return request, nil
+}
+
+// Execute a key rotation request at the given sequence number.
+func (node *Node) executeKeyRotation(request *consensus.RequestMsg) string {
+	var rotation KeyRotation
+	err := json.Unmarshal([]byte(request.Data), &rotation)
+	if err == nil && (rotation.NodeID != request.ClientID || rotation.Timestamp != request.Timestamp) {
+		err = errors.New("key rotation does not match its request")
+	}
+	if err == nil {
+		err = node.Keys.rotate(node.ClusterID, &rotation, request.SequenceID, node.Params.KeyGracePeriod)
+	}
+	if err != nil {
+		fmt.Printf("Key rotation at sequence %d rejected: %v\n", request.SequenceID, err)
+		return "ERROR " + err.Error()
+	}
+
+	fmt.Printf("Key of %s rotates at sequence %d\n", rotation.NodeID, request.SequenceID + node.Params.KeyGracePeriod)
+	return "OK"
+}
+
+// Switch keys once the sequence number is executed. This node signs
+// with its new key from then on, if it has the private key.
+func (node *Node) advanceKeys(sequenceID int64) {
+	for _, nodeID := range node.Keys.advance(sequenceID) {
+		fmt.Printf("Key of %s rotated at sequence %d\n", nodeID, sequenceID)
+		if nodeID != node.MyInfo.NodeID {
+			continue
+		}
+
+		node.signer.mutex.Lock()
+		next := node.signer.next
+		if next != nil && node.Keys.isCurrent(nodeID, next) {
+			node.signer.signer = next
+		} else {
+			node.MsgError <- []error{fmt.Errorf("no private key for the new key of %s; its messages will be rejected", nodeID)}
+		}
+		node.signer.next = nil
+		node.signer.mutex.Unlock()
+	}
+}
+
+// Check the signer has the current key of the node.
+func (ring *KeyRing) isCurrent(nodeID string, signer consensus.Signer) bool {
+	ring.mutex.RLock()
+	keys := ring.keys[nodeID]
+	ring.mutex.RUnlock()
+
+	data := []byte("key check")
+	signature, err := signer.Sign(data)
+
+	return err == nil && keys != nil && keys.current.Verify(signature, data)
+}
@@ -0,0 +1,32 @@
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/checker"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+)
+
+// KVStore is the replicated state machine. Committed requests are
+// applied in sequence number order by executeMsg only, so it needs
+// no locking.
+type KVStore struct {
+	data map[string]string
+}
+
+func NewKVStore() *KVStore {
+	return &KVStore{data: make(map[string]string)}
+}
+
+// Execute applies the operation of the request and returns its result.
+func (kv *KVStore) Execute(request *consensus.RequestMsg) string {
+	switch request.Operation {
+	case checker.OpPut:
+		key, value := checker.SplitPut(request.Data)
+		kv.data[key] = value
+		return "OK"
+	case checker.OpGet:
+		return kv.data[request.Data]
+	}
+
+	// Not a key-value operation (e.g., "Op1" of bench).
+	return checker.ResultExecuted
+}
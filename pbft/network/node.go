@@ -1,7 +1,9 @@
 package network
 
 import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/checker"
 	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -9,19 +11,25 @@ import (
 	"context"
 	"sync"
 	"sync/atomic"
-	"crypto/ecdsa"
 )
 
 type Node struct {
 	MyInfo          *NodeInfo
-	PrivKey         *ecdsa.PrivateKey
+	ClusterID       string // signed into every message
+	Params          Params
+	Signer          consensus.Signer // replaced when the key is rotated
 	NodeTable       []*NodeInfo
+	Keys            *KeyRing // current public keys of the nodes
+	ClientTable     []*ClientInfo // clients allowed to send requests
 	View            *View
+	Hub             *Hub // fans out outbound messages to the subscribed peers
+	Transport       Transport
 	States          map[int64]consensus.PBFT // key: sequenceID, value: state
 	VCStates		map[int64]*consensus.VCState
 	CommittedMsgs   []*consensus.RequestMsg // kinda block.
 	TotalConsensus  int64 // atomic. number of consensus started so far.
 	IsViewChanging  bool
+	KVStore         *KVStore
 
 	// Channels
 	MsgEntrance   chan interface{}
@@ -34,6 +42,68 @@ type Node struct {
 	// Mutexes for preventing from concurrent access
 	StatesMutex sync.RWMutex
 	VCStatesMutex sync.RWMutex
+	CommittedMsgsMutex sync.RWMutex
+
+	// Same as Signer.
+	signer          *nodeSigner
+
+	// Timestamp of the last request accepted from each client, and
+	// the last request executed for each client with its reply.
+	// key: clientID
+	lastRequests       map[string]int64
+	lastReplies        map[string]*MsgPair
+	clientRequestsMutex sync.Mutex
+
+	// Waiting for requests to be executed (see gateway.go).
+	// key: clientID/timestamp
+	executionWaiters      map[string][]chan *MsgPair
+	executionWaitersMutex sync.Mutex
+
+	// Requests executed by this node (see status.go).
+	// key: clientID/timestamp, and digest
+	executedRequests      map[string]*executedRequest
+	executedDigests       map[string]*executedRequest
+	executedRequestsMutex sync.RWMutex
+
+	// Executed requests in sequence number order from trimmedBefore
+	// on, the earlier ones being in the durable log if any, and a
+	// channel closed at the next execution (see events.go).
+	executedLog    []*executedRequest
+	trimmedBefore  int64
+	eventLog       *eventLog
+	executedSignal chan struct{}
+
+	// COMMIT messages as their replicas signed them, for the
+	// certificates of the executed requests (see events.go).
+	// key: sequenceID, value: map(key: nodeID)
+	signedCommits      map[int64]map[string]*keptCommit
+	signedCommitsMutex sync.Mutex
+
+	// Requests accepted and not executed yet (see request.go).
+	// key: clientID/timestamp
+	pendingRequests      map[string]*pendingRequest
+	pendingRequestsMutex sync.Mutex
+
+	// Request timer of a backup, running while requests are pending
+	// (see request.go). Guarded by pendingRequestsMutex.
+	requestTimer *time.Timer
+
+	// Rates of requests admitted from each client, and from all
+	// clients (see admission.go). key: clientID, or "" for all
+	rateLimiters      map[string]*rateLimiter
+	rateLimitersMutex sync.Mutex
+
+	// Requests reported as delayed by the primary (see fairness.go).
+	// key: clientID, value: number of requests
+	delayedRequests   map[string]int
+	delayReports      []*DelayReport
+	delayReportsMutex sync.Mutex
+
+	// Secret authenticating the handshake nonces of this node, and
+	// the nonces used in handshakes until they expire (see auth.go).
+	nonceKey        []byte
+	usedNonces      map[string]time.Time
+	usedNoncesMutex sync.Mutex
 
 	// Saved checkpoint messages on this node
 	// key: sequenceID, value: map(key: nodeID, value: checkpointMsg)
@@ -45,9 +115,16 @@ type Node struct {
 }
 
 type NodeInfo struct {
-	NodeID string `json:"nodeID"`
-	Url    string `json:"url"`
-	PubKey *ecdsa.PublicKey
+	NodeID  string `json:"nodeID"`
+	Url     string `json:"url"`
+	GrpcUrl string `json:"grpcUrl,omitempty"` // gRPC transport and Submit RPC
+	PubKey  consensus.Verifier `json:"-"` // initial key (see KeyRing)
+}
+
+// Client identified by the key it signs its requests with.
+type ClientInfo struct {
+	ClientID string `json:"clientID"`
+	PubKey   consensus.Verifier `json:"-"`
 }
 
 type View struct {
@@ -62,37 +139,87 @@ type MsgPair struct {
 
 // Outbound message
 type MsgOut struct {
-	Path string
-	Msg  []byte
+	Path string // message type, e.g., "/prepare"
+	Msg  interface{}
+}
+
+// Message on a peer connection. All message types share one
+// connection per peer, so each message is tagged with its type.
+//
+// Messages to a subscribed peer carry the epoch of the sending hub and
+// a per-peer sequence number. The peer acknowledges them cumulatively
+// with "/ack" messages, and announces itself with a "/hello" message
+// carrying the last epoch and sequence number it received.
+type PeerMsg struct {
+	Type   string          `json:"type"`
+	Msg    json.RawMessage `json:"msg,omitempty"` // signed message
+	Epoch  int64           `json:"epoch,omitempty"`
+	Seq    uint64          `json:"seq,omitempty"`
+	NodeID string          `json:"nodeID,omitempty"` // "/hello" only
+
+	// Subscriber the message is for, or all replicas if empty,
+	// e.g., the client of a "/reply". Not sent.
+	to string
 }
 
+// Cluster ID signed into messages unless configured otherwise.
+const DefaultClusterID = "pbft"
+
 // Number of parallel goroutines for resolving messages.
 const NumResolveMsgGo = 6
 
-// Deadline for the consensus state.
-const ConsensusDeadline = time.Millisecond * 100
-
 // Cooling time to escape frequent error, or message sending retry.
 const CoolingTime = time.Millisecond * 2
 
 // Number of error messages to start cooling.
 const CoolingTotalErrMsg = 5
 
-// Number of outbound connection for a node.
-const MaxOutboundConnection = 1000
+// Number of outbound messages queued for a peer connection.
+const PeerSendQueueSize = 256
+
+// Unacknowledged messages kept for each peer. The oldest messages
+// are dropped beyond these limits.
+const MaxUnackedMsgs = 4096
+const MaxUnackedBytes = 64 << 20
+
+// Messages not acknowledged within RetransmitTimeout are sent again.
+const RetransmitTimeout = time.Second
+const RetransmitCheckPeriod = time.Millisecond * 100
+
+func NewNode(myInfo *NodeInfo, nodeTable []*NodeInfo, clientTable []*ClientInfo, viewID int64, clusterID string, signer consensus.Signer) *Node {
+	rotatingSigner := &nodeSigner{signer: signer}
 
-func NewNode(myInfo *NodeInfo, nodeTable []*NodeInfo, viewID int64, decodePrivKey *ecdsa.PrivateKey) *Node {
 	node := &Node{
 		MyInfo:    myInfo,
-		PrivKey: decodePrivKey,
+		ClusterID: clusterID,
+		Params:    DefaultParams,
+		Signer:    rotatingSigner,
+		signer:    rotatingSigner,
 		NodeTable: nodeTable,
+		Keys:      NewKeyRing(nodeTable),
+		ClientTable: clientTable,
 		View:      &View{},
+		Hub:       NewHub(nodeTable),
 		IsViewChanging: false,
 
 		// Consensus-related struct
 		States:          make(map[int64]consensus.PBFT),
 		CommittedMsgs:   make([]*consensus.RequestMsg, 0),
 		VCStates: 		 make(map[int64]*consensus.VCState),
+		KVStore:         NewKVStore(),
+
+		lastRequests:    make(map[string]int64),
+		lastReplies:     make(map[string]*MsgPair),
+		executionWaiters: make(map[string][]chan *MsgPair),
+		pendingRequests: make(map[string]*pendingRequest),
+		rateLimiters:    make(map[string]*rateLimiter),
+		delayedRequests: make(map[string]int),
+		executedRequests: make(map[string]*executedRequest),
+		executedDigests: make(map[string]*executedRequest),
+		executedSignal: make(chan struct{}),
+		signedCommits: make(map[int64]map[string]*keptCommit),
+		nonceKey:      make([]byte, 32),
+		usedNonces:    make(map[string]time.Time),
 
 		// Channels
 		MsgEntrance: make(chan interface{}, len(nodeTable) * 3),
@@ -106,9 +233,24 @@ func NewNode(myInfo *NodeInfo, nodeTable []*NodeInfo, viewID int64, decodePrivKe
 		StableCheckPoint:  0,
 	}
 
+	// Websocket transport by default.
+	node.Transport = &wsTransport{
+		hub:       node.Hub,
+		clusterID: clusterID,
+		nodeID:    myInfo.NodeID,
+		signer:    node.Signer,
+	}
+
+	if _, err := rand.Read(node.nonceKey); err != nil {
+		panic(err)
+	}
+
 	atomic.StoreInt64(&node.TotalConsensus, 0)
 	node.updateView(viewID)
 
+	// Start peer connection hub
+	go node.Hub.run()
+
 	// Start message dispatcher
 	go node.dispatchMsg()
 
@@ -129,62 +271,66 @@ func NewNode(myInfo *NodeInfo, nodeTable []*NodeInfo, viewID int64, decodePrivKe
 	return node
 }
 
-// Broadcast marshalled message.
+// Broadcast message to all nodes, including this node.
 func (node *Node) Broadcast(msg interface{}, path string) {
-	jsonMsg, err := json.Marshal(msg)
-	if err != nil {
-		node.MsgError <- []error{err}
-		return
-	}
+	node.MsgOutbound <- &MsgOut{Path: path, Msg: msg}
+}
 
-	node.MsgOutbound <- &MsgOut{Path: node.MyInfo.Url + path, Msg: jsonMsg}
+// Send the reply to the client of the request only.
+func (node *Node) Reply(reply *consensus.ReplyMsg) {
+	node.MsgOutbound <- &MsgOut{Path: "/reply", Msg: reply}
 }
 
-// When REQUEST message is broadcasted, start consensus.
+// When REQUEST message is broadcasted, the primary starts consensus,
+// and the backups wait for the primary to order it (see request.go).
 func (node *Node) GetReq(reqMsg *consensus.RequestMsg) {
 	LogMsg(reqMsg)
 
-	if node.IsViewChanging == true {
+	// Start consensus only on requests signed by their client.
+	if err := node.verifyRequest(reqMsg); err != nil {
+		node.MsgError <- []error{err}
 		return
 	}
 
-	// Create a new state object.
-	state := node.createState(reqMsg.Timestamp)
+	if node.IsViewChanging == true {
+		return
+	}
 
-	// Increment the number of request message atomically.
-	// TODO: Currently, StartConsensus must succeed.
-	newTotalConsensus := atomic.AddInt64(&node.TotalConsensus, 1)
-	prePrepareMsg := state.StartConsensus(reqMsg, newTotalConsensus)
+	if !node.acceptRequest(reqMsg) {
+		return
+	}
 
-	// Register state into node and update last sequence number.
-	node.StatesMutex.Lock()
-	node.States[prePrepareMsg.SequenceID] = state
-	node.StatesMutex.Unlock()
+	node.addPendingRequest(reqMsg)
+	node.orderPendingRequests()
+}
 
-	fmt.Printf("Consensus Process (ViewID: %d, SequenceID: %d)\n",
-	           prePrepareMsg.ViewID, prePrepareMsg.SequenceID)
+// From TOCS: If the request has already been executed, replicas
+// simply re-send the reply; otherwise, requests whose timestamp is not
+// higher than the last one accepted from the client are discarded, as
+// clients retransmit requests to all replicas.
+func (node *Node) acceptRequest(reqMsg *consensus.RequestMsg) bool {
+	node.clientRequestsMutex.Lock()
+	if reqMsg.Timestamp > node.lastRequests[reqMsg.ClientID] {
+		node.lastRequests[reqMsg.ClientID] = reqMsg.Timestamp
+		node.clientRequestsMutex.Unlock()
+		return true
+	}
+	executed := node.lastReplies[reqMsg.ClientID]
+	node.clientRequestsMutex.Unlock()
 
-	// Broadcast PrePrepare message.
-	LogStage("Request", true)
-	if node.isMyNodePrimary() {
-		node.Broadcast(prePrepareMsg, "/preprepare")
+	if executed != nil && executed.replyMsg.Timestamp == reqMsg.Timestamp {
+		node.Reply(executed.replyMsg)
 	}
-	LogStage("Pre-prepare", false)
 
-	// From TOCS: The backups check the sequence numbers assigned by
-	// the primary and use timeouts to detect when it stops.
-	// They trigger view changes to select a new primary when it
-	// appears that the current one has failed.
-	// 
-	// Deadline is determined by the timestamp of the current node.
-	go node.startTransitionWithDeadline(state, time.Now().UnixNano())
+	return false
 }
 
 func (node *Node) startTransitionWithDeadline(state consensus.PBFT, timeStamp int64) {
 	// Set deadline based on the given timestamp.
 	sec := timeStamp / int64(time.Second)
 	nsec := timeStamp % int64(time.Second)
-	d := time.Unix(sec, nsec).Add(ConsensusDeadline)
+	deadline := node.Params.consensusDeadline()
+	d := time.Unix(sec, nsec).Add(deadline)
 	ctx, cancel := context.WithDeadline(context.Background(), d)
 
 	// Check the time is skewed.
@@ -192,7 +338,7 @@ func (node *Node) startTransitionWithDeadline(state consensus.PBFT, timeStamp in
 	fmt.Printf("The deadline for sequenceID %d is %d ms. (Skewed %d ms)\n",
 	           state.GetSequenceID(),
 	           timeDiff / int64(time.Millisecond),
-	           (ConsensusDeadline.Nanoseconds() - timeDiff) / int64(time.Millisecond))
+	           (deadline.Nanoseconds() - timeDiff) / int64(time.Millisecond))
 
 	defer cancel()
 
@@ -214,13 +360,21 @@ func (node *Node) startTransitionWithDeadline(state consensus.PBFT, timeStamp in
 				}
 			}
 		case <-ctx.Done():
+			// The state has been replaced, or discarded, by a new
+			// view (see FillHole), which has its own deadline.
+			if current, _ := node.getState(state.GetSequenceID()); current != state {
+				return
+			}
+
 			// Check the consensus of the current state precedes
 			// that of the last committed message in this node.
 			var lastCommittedMsg *consensus.RequestMsg = nil
+			node.CommittedMsgsMutex.RLock()
 			msgTotalCnt := len(node.CommittedMsgs)
 			if msgTotalCnt > 0 {
 				lastCommittedMsg = node.CommittedMsgs[msgTotalCnt - 1]
 			}
+			node.CommittedMsgsMutex.RUnlock()
 
 			if msgTotalCnt == 0 ||
 			   lastCommittedMsg.SequenceID < state.GetSequenceID() {
@@ -278,6 +432,7 @@ func (node *Node) GetPrepare(state consensus.PBFT, prepareMsg *consensus.VoteMsg
 	commitMsg.NodeID = node.MyInfo.NodeID
 
 	LogStage("Prepare", true)
+	node.keepOwnCommit(commitMsg)
 	node.Broadcast(commitMsg, "/commit")
 	LogStage("Commit", false)
 
@@ -316,6 +471,118 @@ func (node *Node) createState(timeStamp int64) consensus.PBFT {
 	return consensus.CreateState(node.View.ID, node.MyInfo.NodeID, len(node.NodeTable))
 }
 
+// Pass a message of the given type from the transport to the
+// dispatcher. The sender is the node whose signature on the message
+// and its type has been verified. Node IDs inside the message are
+// only declared by the sender, so they must name the sender itself;
+// quorums are counted by them.
+func (node *Node) deliverMsg(msgType string, sender string, msg interface{}) error {
+	if !msgOfType(msgType, msg) {
+		return fmt.Errorf("%T from %s is signed as %s", msg, sender, msgType)
+	}
+
+	// Views are numbered from zero; the primary of a view is found
+	// by its number.
+	if viewID, ok := viewOfMsg(msg); ok && viewID < 0 {
+		return fmt.Errorf("%T from %s is for negative view %d", msg, sender, viewID)
+	}
+
+	var declared string
+	switch m := msg.(type) {
+	case *consensus.PrePrepareMsg:
+		declared = node.getPrimaryInfoByID(m.ViewID).NodeID
+	case *consensus.VoteMsg:
+		declared = m.NodeID
+	case *consensus.ReplyMsg:
+		declared = m.NodeID
+	case *consensus.CheckPointMsg:
+		declared = m.NodeID
+	case *consensus.ViewChangeMsg:
+		declared = m.NodeID
+	case *consensus.NewViewMsg:
+		declared = m.NodeID
+	default:
+		declared = sender
+	}
+	if declared != sender {
+		return fmt.Errorf("%T from %s claims to be from %s", msg, sender, declared)
+	}
+
+	// The PRE-PREPARE messages of a new primary may arrive before its
+	// NEW-VIEW message.
+	switch msg.(type) {
+	case *consensus.PrePrepareMsg, *consensus.VoteMsg, *consensus.ReplyMsg:
+		if !node.IsViewChanging {
+			node.MsgEntrance <- msg
+		} else {
+			node.ViewMsgEntrance <- msg
+		}
+	case *consensus.ViewChangeMsg, *consensus.NewViewMsg:
+		node.ViewMsgEntrance <- msg
+	default:
+		node.MsgEntrance <- msg
+	}
+
+	return nil
+}
+
+// View of a message, or false for messages without one.
+func viewOfMsg(msg interface{}) (int64, bool) {
+	switch m := msg.(type) {
+	case *consensus.PrePrepareMsg:
+		return m.ViewID, true
+	case *consensus.VoteMsg:
+		return m.ViewID, true
+	case *consensus.ReplyMsg:
+		return m.ViewID, true
+	case *consensus.ViewChangeMsg:
+		return m.NextViewID, true
+	case *consensus.NewViewMsg:
+		return m.NextViewID, true
+	}
+
+	return 0, false
+}
+
+// Check the request is signed by the client named in it. Replicas
+// may send requests under their node IDs, e.g., key rotations.
+func (node *Node) verifyRequest(reqMsg *consensus.RequestMsg) error {
+	var pubKey consensus.Verifier
+	if clientInfo := findClientInfo(node.ClientTable, reqMsg.ClientID); clientInfo != nil {
+		pubKey = clientInfo.PubKey
+	} else if verifier := node.Keys.Verifier(reqMsg.ClientID); verifier != nil {
+		pubKey = verifier
+	} else {
+		return fmt.Errorf("request from unknown client %q (timestamp %d)", reqMsg.ClientID, reqMsg.Timestamp)
+	}
+
+	if !reqMsg.Verify(node.ClusterID, pubKey) {
+		return fmt.Errorf("invalid signature on request from %s (timestamp %d)", reqMsg.ClientID, reqMsg.Timestamp)
+	}
+
+	return nil
+}
+
+func findClientInfo(clientTable []*ClientInfo, clientID string) *ClientInfo {
+	for _, clientInfo := range clientTable {
+		if clientInfo.ClientID == clientID {
+			return clientInfo
+		}
+	}
+
+	return nil
+}
+
+func findNodeInfo(nodeTable []*NodeInfo, nodeID string) *NodeInfo {
+	for _, nodeInfo := range nodeTable {
+		if nodeInfo.NodeID == nodeID {
+			return nodeInfo
+		}
+	}
+
+	return nil
+}
+
 func (node *Node) dispatchMsg() {
 	for {
 		select {
@@ -334,10 +601,11 @@ func (node *Node) routeMsg(msgEntered interface{}) {
 	case *consensus.RequestMsg:
 		node.MsgDelivery <- msg
 	case *consensus.PrePrepareMsg:
-		// Receive pre-prepare message only if 1. the node is not primary,
-		// and 2. stable checkpoint for this node is lower than
-		// sequence number of this message.
-		if !node.isMyNodePrimary() &&
+		// Receive pre-prepare message only if 1. the node is not primary
+		// of its view, and 2. stable checkpoint for this node is lower
+		// than sequence number of this message.
+		primary := node.getPrimaryInfoByID(msg.ViewID)
+		if primary != nil && primary.NodeID != node.MyInfo.NodeID &&
 		   node.StableCheckPoint <= msg.SequenceID {
 			node.MsgDelivery <- msg
 		}
@@ -378,12 +646,18 @@ func (node *Node) resolveMsg() {
 		case *consensus.RequestMsg:
 			node.GetReq(msg)
 		case *consensus.PrePrepareMsg:
-			state, err = node.getState(msg.SequenceID)
+			state, err = node.getPrePrepareState(msg)
 			if state != nil {
 				ch := state.GetMsgSendChannel()
 				ch <- msg
 			}
 		case *consensus.VoteMsg:
+			// Votes of a new view may arrive before its NEW-VIEW
+			// message installs the states of the view.
+			if msg.ViewID > node.View.ID {
+				err = fmt.Errorf("vote for sequence number %d is of view %d, not installed yet", msg.SequenceID, msg.ViewID)
+				break
+			}
 			state, err = node.getState(msg.SequenceID)
 			if state != nil {
 				ch := state.GetMsgSendChannel()
@@ -402,8 +676,13 @@ func (node *Node) resolveMsg() {
 		if err != nil {
 			// Print error.
 			node.MsgError <- []error{err}
-			// Send message into dispatcher.
-			node.MsgEntrance <- msgDelivered
+			// Send message into dispatcher, which drops the
+			// messages of MsgEntrance during a view change.
+			if !node.IsViewChanging {
+				node.MsgEntrance <- msgDelivered
+			} else {
+				node.ViewMsgEntrance <- msgDelivered
+			}
 		}
 	}
 }
@@ -456,28 +735,45 @@ func (node *Node) executeMsg() {
 			committedMsgs = append(committedMsgs, p.committedMsg)
 			LogStage("Commit", true)
 
-			// TODO: execute appropriate operation.
-			p.replyMsg.Result = "Executed"
+			if p.committedMsg.Operation == OpRotateKey {
+				p.replyMsg.Result = node.executeKeyRotation(p.committedMsg)
+			} else {
+				p.replyMsg.Result = node.KVStore.Execute(p.committedMsg)
+			}
+			node.advanceKeys(lastSequenceID + 1)
 
 			// After executing the operation, log the
 			// corresponding committed message to node.
+			node.CommittedMsgsMutex.Lock()
 			node.CommittedMsgs = append(node.CommittedMsgs, p.committedMsg)
-
-			// Broadcast reply.
-			node.Broadcast(p.replyMsg, "/reply")
+			node.CommittedMsgsMutex.Unlock()
+
+			// Send reply, and keep it for retransmitted
+			// requests. An executed request is not accepted
+			// again, even if it arrives only now.
+			node.clientRequestsMutex.Lock()
+			node.lastReplies[p.replyMsg.ClientID] = p
+			if p.committedMsg.Timestamp > node.lastRequests[p.committedMsg.ClientID] {
+				node.lastRequests[p.committedMsg.ClientID] = p.committedMsg.Timestamp
+			}
+			node.clientRequestsMutex.Unlock()
+			node.removePendingRequest(p.committedMsg)
+			node.recordExecution(p)
+			node.Reply(p.replyMsg)
+			node.notifyExecution(p)
 			LogStage("Reply", true)
 
-			// Create checkpoint every `periodCheckPoint` committed message.
-			if (lastSequenceID + 1) % periodCheckPoint == 0 {
+			// Create checkpoint every `CheckPointPeriod` committed message.
+			if (lastSequenceID + 1) % node.Params.CheckPointPeriod == 0 {
 				LogStage("CHECKPOINT", false)
 				// Send CHECKPOINT message until it is possible.
 				for sequenceid := node.StableCheckPoint;
 				    sequenceid < lastSequenceID + 1;
-				    sequenceid += periodCheckPoint {
+				    sequenceid += node.Params.CheckPointPeriod {
 					if !node.CheckPointMissCheck(sequenceid) {
 						break
 					}
-					checkPointMsg := node.createCheckPointMsg(sequenceid + periodCheckPoint, node.MyInfo.NodeID)
+					checkPointMsg := node.createCheckPointMsg(sequenceid + node.Params.CheckPointPeriod, node.MyInfo.NodeID)
 					node.Broadcast(checkPointMsg, "/checkpoint")
 					node.CheckPoint(checkPointMsg)
 				}
@@ -486,44 +782,58 @@ func (node *Node) executeMsg() {
 			delete(pairs, lastSequenceID + 1)
 		}
 
+		// Executed requests make room for the waiting ones.
+		if len(committedMsgs) > 0 {
+			node.orderPendingRequests()
+		}
+
 		// Print all committed messages.
+		// The states may be gone with a checkpoint already.
 		for _, v := range committedMsgs {
-			state, _ := node.getState(v.SequenceID) // Must succeed.
-			digest := state.GetDigest()
+			digest := v.Digest()
 			fmt.Printf("***committedMsgs[%d]: clientID=%s, operation=%s, timestamp=%d, ReqMsg (digest)=%s***\n",
 			           v.SequenceID, v.ClientID, v.Operation, v.Timestamp, digest)
 		}
 	}
 }
 
+// Hand outbound messages to the transport in order.
 func (node *Node) sendMsg() {
-	sem := make(chan bool, MaxOutboundConnection)
-
 	for {
 		msg := <-node.MsgOutbound
 
-		// Goroutine for concurrent broadcast() with timeout
-		sem <- true
-		go func() {
-			defer func() { <-sem }()
-			errCh := make(chan error, 1)
-
-			// Goroutine for concurrent broadcast()
-			go func() {
-				broadcast(errCh, msg.Path, msg.Msg, node.PrivKey)
-			}()
-
-			select {
-			case err := <-errCh:
-				if err != nil {
-					node.MsgError <- []error{err}
-					// TODO: view change.
-				}
-			}
-		}()
+		var err error
+		if reply, ok := msg.Msg.(*consensus.ReplyMsg); ok {
+			err = node.sendReply(reply)
+		} else {
+			err = node.Transport.Broadcast(msg.Path, msg.Msg)
+		}
+		if err != nil {
+			node.MsgError <- []error{err}
+		}
 	}
 }
 
+// From TOCS: Each replica sends the reply directly to the client.
+// Clients get it in their session on the hub of this node; replicas
+// sending requests as clients (e.g., key rotations) get it over
+// the transport. Other replicas do not get it.
+func (node *Node) sendReply(reply *consensus.ReplyMsg) error {
+	if findNodeInfo(node.NodeTable, reply.ClientID) != nil {
+		return node.Transport.Send(reply.ClientID, "/reply", reply)
+	}
+
+	peerMsg, err := signPeerMsg("/reply", reply, node.ClusterID, node.MyInfo.NodeID, node.Signer)
+	if err != nil {
+		return err
+	}
+	peerMsg.to = reply.ClientID
+
+	node.Hub.broadcast <- peerMsg
+
+	return nil
+}
+
 func (node *Node) logErrorMsg() {
 	coolingMsgLeft := CoolingTotalErrMsg
 
@@ -553,3 +863,16 @@ func (node *Node) getState(sequenceID int64) (consensus.PBFT, error) {
 
 	return state, nil
 }
+
+// Snapshot of the committed log for cross-replica comparison.
+func (node *Node) getCommittedEntries() []checker.CommittedEntry {
+	node.CommittedMsgsMutex.RLock()
+	defer node.CommittedMsgsMutex.RUnlock()
+
+	entries := make([]checker.CommittedEntry, len(node.CommittedMsgs))
+	for i, request := range node.CommittedMsgs {
+		entries[i] = checker.NewCommittedEntry(request, request.Digest())
+	}
+
+	return entries
+}
@@ -0,0 +1,83 @@
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"math"
+	"testing"
+)
+
+// Node with a table of four replicas, which only delivers messages.
+func newDeliveryNode() *Node {
+	return &Node{
+		NodeTable: []*NodeInfo{
+			{NodeID: "Node1"},
+			{NodeID: "Node2"},
+			{NodeID: "Node3"},
+			{NodeID: "Node4"},
+		},
+		MsgEntrance:     make(chan interface{}, 1),
+		ViewMsgEntrance: make(chan interface{}, 1),
+	}
+}
+
+func TestDeliverPrePrepare(t *testing.T) {
+	tests := []struct {
+		name      string
+		sender    string
+		viewID    int64
+		delivered bool
+	}{
+		{"primary of view 0", "Node1", 0, true},
+		{"primary of view 5", "Node2", 5, true},
+		{"backup of the view", "Node2", 0, false},
+		{"negative view", "Node4", -1, false},
+		{"negative view of the sender", "Node1", -4, false},
+		{"lowest view", "Node1", math.MinInt64, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := newDeliveryNode()
+			msg := &consensus.PrePrepareMsg{ViewID: test.viewID, SequenceID: 1, Digest: "digest"}
+
+			err := node.deliverMsg("/preprepare", test.sender, msg)
+			if test.delivered && err != nil {
+				t.Fatalf("PRE-PREPARE is refused: %v", err)
+			}
+			if !test.delivered && err == nil {
+				t.Fatalf("PRE-PREPARE is delivered")
+			}
+			if delivered := len(node.MsgEntrance) > 0; delivered != test.delivered {
+				t.Errorf("PRE-PREPARE is queued: %v, want %v", delivered, test.delivered)
+			}
+		})
+	}
+}
+
+func TestDeliverNegativeView(t *testing.T) {
+	msgs := map[string]interface{}{
+		"/prepare":    &consensus.VoteMsg{ViewID: -1, NodeID: "Node2", MsgType: consensus.PrepareMsg},
+		"/commit":     &consensus.VoteMsg{ViewID: -1, NodeID: "Node2", MsgType: consensus.CommitMsg},
+		"/reply":      &consensus.ReplyMsg{ViewID: -1, NodeID: "Node2"},
+		"/viewchange": &consensus.ViewChangeMsg{NextViewID: -1, NodeID: "Node2"},
+		"/newview":    &consensus.NewViewMsg{NextViewID: -1, NodeID: "Node2"},
+	}
+
+	for msgType, msg := range msgs {
+		node := newDeliveryNode()
+		if err := node.deliverMsg(msgType, "Node2", msg); err == nil {
+			t.Errorf("%s message for a negative view is delivered", msgType)
+		}
+	}
+}
+
+func TestGetPrimaryInfoByID(t *testing.T) {
+	node := newDeliveryNode()
+
+	if primary := node.getPrimaryInfoByID(-1); primary != nil {
+		t.Errorf("primary of view -1 is %s", primary.NodeID)
+	}
+	if primary := node.getPrimaryInfoByID(6); primary == nil || primary.NodeID != "Node3" {
+		t.Errorf("primary of view 6 is %v, want Node3", primary)
+	}
+}
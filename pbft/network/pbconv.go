@@ -0,0 +1,300 @@
+// Conversion between consensus messages and their protobuf form
+// used by the gRPC transport.
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"github.com/bigpicturelabs/consensusPBFT/pbft/pbftpb"
+	"fmt"
+)
+
+func requestToPb(m *consensus.RequestMsg) *pbftpb.RequestMsg {
+	if m == nil {
+		return nil
+	}
+
+	return &pbftpb.RequestMsg{
+		Timestamp:  m.Timestamp,
+		ClientId:   m.ClientID,
+		Operation:  m.Operation,
+		Data:       m.Data,
+		SequenceId: m.SequenceID,
+		Signature:  m.Signature,
+	}
+}
+
+func requestFromPb(m *pbftpb.RequestMsg) *consensus.RequestMsg {
+	return &consensus.RequestMsg{
+		Timestamp:  m.GetTimestamp(),
+		ClientID:   m.GetClientId(),
+		Operation:  m.GetOperation(),
+		Data:       m.GetData(),
+		SequenceID: m.GetSequenceId(),
+		Signature:  m.GetSignature(),
+	}
+}
+
+func replyToPb(m *consensus.ReplyMsg) *pbftpb.ReplyMsg {
+	return &pbftpb.ReplyMsg{
+		ViewId:    m.ViewID,
+		Timestamp: m.Timestamp,
+		ClientId:  m.ClientID,
+		NodeId:    m.NodeID,
+		Result:    m.Result,
+	}
+}
+
+func replyFromPb(m *pbftpb.ReplyMsg) *consensus.ReplyMsg {
+	return &consensus.ReplyMsg{
+		ViewID:    m.GetViewId(),
+		Timestamp: m.GetTimestamp(),
+		ClientID:  m.GetClientId(),
+		NodeID:    m.GetNodeId(),
+		Result:    m.GetResult(),
+	}
+}
+
+func prePrepareToPb(m *consensus.PrePrepareMsg) *pbftpb.PrePrepareMsg {
+	if m == nil {
+		return nil
+	}
+
+	return &pbftpb.PrePrepareMsg{
+		ViewId:     m.ViewID,
+		SequenceId: m.SequenceID,
+		Digest:     m.Digest,
+		RequestMsg: requestToPb(m.RequestMsg),
+	}
+}
+
+func prePrepareFromPb(m *pbftpb.PrePrepareMsg) *consensus.PrePrepareMsg {
+	if m == nil {
+		return nil
+	}
+
+	var request *consensus.RequestMsg
+	if m.GetRequestMsg() != nil {
+		request = requestFromPb(m.GetRequestMsg())
+	}
+
+	return &consensus.PrePrepareMsg{
+		ViewID:     m.GetViewId(),
+		SequenceID: m.GetSequenceId(),
+		Digest:     m.GetDigest(),
+		RequestMsg: request,
+	}
+}
+
+func voteToPb(m *consensus.VoteMsg) *pbftpb.VoteMsg {
+	if m == nil {
+		return nil
+	}
+
+	return &pbftpb.VoteMsg{
+		ViewId:     m.ViewID,
+		SequenceId: m.SequenceID,
+		Digest:     m.Digest,
+		NodeId:     m.NodeID,
+		MsgType:    pbftpb.MsgType(m.MsgType),
+	}
+}
+
+func voteFromPb(m *pbftpb.VoteMsg) *consensus.VoteMsg {
+	if m == nil {
+		return nil
+	}
+
+	return &consensus.VoteMsg{
+		ViewID:     m.GetViewId(),
+		SequenceID: m.GetSequenceId(),
+		Digest:     m.GetDigest(),
+		NodeID:     m.GetNodeId(),
+		MsgType:    consensus.MsgType(m.GetMsgType()),
+	}
+}
+
+func checkPointToPb(m *consensus.CheckPointMsg) *pbftpb.CheckPointMsg {
+	if m == nil {
+		return nil
+	}
+
+	return &pbftpb.CheckPointMsg{
+		SequenceId: m.SequenceID,
+		Digest:     m.Digest,
+		NodeId:     m.NodeID,
+	}
+}
+
+func checkPointFromPb(m *pbftpb.CheckPointMsg) *consensus.CheckPointMsg {
+	if m == nil {
+		return nil
+	}
+
+	return &consensus.CheckPointMsg{
+		SequenceID: m.GetSequenceId(),
+		Digest:     m.GetDigest(),
+		NodeID:     m.GetNodeId(),
+	}
+}
+
+func viewChangeToPb(m *consensus.ViewChangeMsg) *pbftpb.ViewChangeMsg {
+	if m == nil {
+		return nil
+	}
+
+	setC := make(map[string]*pbftpb.CheckPointMsg)
+	for k, v := range m.SetC {
+		setC[k] = checkPointToPb(v)
+	}
+
+	setP := make(map[int64]*pbftpb.SetPm)
+	for k, v := range m.SetP {
+		if v == nil {
+			continue
+		}
+		prepareMsgs := make(map[string]*pbftpb.VoteMsg)
+		for nodeID, prepareMsg := range v.PrepareMsgs {
+			prepareMsgs[nodeID] = voteToPb(prepareMsg)
+		}
+		setP[k] = &pbftpb.SetPm{
+			PrePrepareMsg: prePrepareToPb(v.PrePrepareMsg),
+			PrepareMsgs:   prepareMsgs,
+		}
+	}
+
+	return &pbftpb.ViewChangeMsg{
+		NodeId:           m.NodeID,
+		NextViewId:       m.NextViewID,
+		StableCheckPoint: m.StableCheckPoint,
+		SetC:             setC,
+		SetP:             setP,
+	}
+}
+
+func viewChangeFromPb(m *pbftpb.ViewChangeMsg) *consensus.ViewChangeMsg {
+	if m == nil {
+		return nil
+	}
+
+	setC := make(map[string]*consensus.CheckPointMsg)
+	for k, v := range m.GetSetC() {
+		setC[k] = checkPointFromPb(v)
+	}
+
+	setP := make(map[int64]*consensus.SetPm)
+	for k, v := range m.GetSetP() {
+		prepareMsgs := make(map[string]*consensus.VoteMsg)
+		for nodeID, prepareMsg := range v.GetPrepareMsgs() {
+			prepareMsgs[nodeID] = voteFromPb(prepareMsg)
+		}
+		setP[k] = &consensus.SetPm{
+			PrePrepareMsg: prePrepareFromPb(v.GetPrePrepareMsg()),
+			PrepareMsgs:   prepareMsgs,
+		}
+	}
+
+	return &consensus.ViewChangeMsg{
+		NodeID:           m.GetNodeId(),
+		NextViewID:       m.GetNextViewId(),
+		StableCheckPoint: m.GetStableCheckPoint(),
+		SetC:             setC,
+		SetP:             setP,
+	}
+}
+
+func newViewToPb(m *consensus.NewViewMsg) *pbftpb.NewViewMsg {
+	setViewChangeMsgs := make(map[string]*pbftpb.ViewChangeMsg)
+	for k, v := range m.SetViewChangeMsgs {
+		setViewChangeMsgs[k] = viewChangeToPb(v)
+	}
+
+	setPrePrepareMsgs := make(map[int64]*pbftpb.PrePrepareMsg)
+	for k, v := range m.SetPrePrepareMsgs {
+		setPrePrepareMsgs[k] = prePrepareToPb(v)
+	}
+
+	return &pbftpb.NewViewMsg{
+		NodeId:            m.NodeID,
+		NextViewId:        m.NextViewID,
+		SetViewChangeMsgs: setViewChangeMsgs,
+		SetPrePrepareMsgs: setPrePrepareMsgs,
+		MaxS:              m.Max_S,
+		MinS:              m.Min_S,
+	}
+}
+
+func newViewFromPb(m *pbftpb.NewViewMsg) *consensus.NewViewMsg {
+	setViewChangeMsgs := make(map[string]*consensus.ViewChangeMsg)
+	for k, v := range m.GetSetViewChangeMsgs() {
+		setViewChangeMsgs[k] = viewChangeFromPb(v)
+	}
+
+	setPrePrepareMsgs := make(map[int64]*consensus.PrePrepareMsg)
+	for k, v := range m.GetSetPrePrepareMsgs() {
+		setPrePrepareMsgs[k] = prePrepareFromPb(v)
+	}
+
+	return &consensus.NewViewMsg{
+		NodeID:            m.GetNodeId(),
+		NextViewID:        m.GetNextViewId(),
+		SetViewChangeMsgs: setViewChangeMsgs,
+		SetPrePrepareMsgs: setPrePrepareMsgs,
+		Max_S:             m.GetMaxS(),
+		Min_S:             m.GetMinS(),
+	}
+}
+
+// Wrap a consensus message of the given type into an envelope.
+func envelopeOf(msgType string, msg interface{}) (*pbftpb.Envelope, error) {
+	env := &pbftpb.Envelope{}
+
+	switch m := msg.(type) {
+	case *consensus.RequestMsg:
+		env.Msg = &pbftpb.Envelope_Request{Request: requestToPb(m)}
+	case *consensus.PrePrepareMsg:
+		env.Msg = &pbftpb.Envelope_PrePrepare{PrePrepare: prePrepareToPb(m)}
+	case *consensus.VoteMsg:
+		if msgType == "/commit" {
+			env.Msg = &pbftpb.Envelope_Commit{Commit: voteToPb(m)}
+		} else {
+			env.Msg = &pbftpb.Envelope_Prepare{Prepare: voteToPb(m)}
+		}
+	case *consensus.ReplyMsg:
+		env.Msg = &pbftpb.Envelope_Reply{Reply: replyToPb(m)}
+	case *consensus.CheckPointMsg:
+		env.Msg = &pbftpb.Envelope_Checkpoint{Checkpoint: checkPointToPb(m)}
+	case *consensus.ViewChangeMsg:
+		env.Msg = &pbftpb.Envelope_ViewChange{ViewChange: viewChangeToPb(m)}
+	case *consensus.NewViewMsg:
+		env.Msg = &pbftpb.Envelope_NewView{NewView: newViewToPb(m)}
+	default:
+		return nil, fmt.Errorf("cannot send %T (%s) over gRPC", msg, msgType)
+	}
+
+	return env, nil
+}
+
+// Unwrap the consensus message carried by an envelope, with its type.
+func msgOfEnvelope(env *pbftpb.Envelope) (string, interface{}, error) {
+	switch m := env.GetMsg().(type) {
+	case *pbftpb.Envelope_Request:
+		return "/req", requestFromPb(m.Request), nil
+	case *pbftpb.Envelope_PrePrepare:
+		return "/preprepare", prePrepareFromPb(m.PrePrepare), nil
+	case *pbftpb.Envelope_Prepare:
+		return "/prepare", voteFromPb(m.Prepare), nil
+	case *pbftpb.Envelope_Commit:
+		return "/commit", voteFromPb(m.Commit), nil
+	case *pbftpb.Envelope_Reply:
+		return "/reply", replyFromPb(m.Reply), nil
+	case *pbftpb.Envelope_Checkpoint:
+		return "/checkpoint", checkPointFromPb(m.Checkpoint), nil
+	case *pbftpb.Envelope_ViewChange:
+		return "/viewchange", viewChangeFromPb(m.ViewChange), nil
+	case *pbftpb.Envelope_NewView:
+		return "/newview", newViewFromPb(m.NewView), nil
+	}
+
+	return "", nil, fmt.Errorf("envelope from %s carries no message", env.GetSender())
+}
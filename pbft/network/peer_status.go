@@ -0,0 +1,137 @@
+package network
+
+import (
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Connection state of the subscription to a peer.
+type PeerState int
+
+const (
+	PeerConnecting PeerState = iota
+	PeerConnected
+	PeerDisconnected
+)
+
+func (state PeerState) String() string {
+	switch state {
+	case PeerConnecting:
+		return "connecting"
+	case PeerConnected:
+		return "connected"
+	case PeerDisconnected:
+		return "disconnected"
+	}
+
+	return "unknown"
+}
+
+type PeerStatus struct {
+	NodeID     string    `json:"nodeID"`
+	Url        string    `json:"url"`
+	State      string    `json:"state"`
+	Since      time.Time `json:"since"`
+	Attempts   int       `json:"attempts"` // failed dials since the last connection
+	Reconnects int       `json:"reconnects"`
+	LastError  string    `json:"lastError,omitempty"`
+}
+
+// PeerTable tracks the connection state of every peer so operators
+// can see which links are down.
+type PeerTable struct {
+	mutex sync.RWMutex
+	peers map[string]*PeerStatus // key: nodeID
+}
+
+func NewPeerTable(nodeTable []*NodeInfo) *PeerTable {
+	table := &PeerTable{peers: make(map[string]*PeerStatus)}
+
+	for _, nodeInfo := range nodeTable {
+		table.peers[nodeInfo.NodeID] = &PeerStatus{
+			NodeID: nodeInfo.NodeID,
+			Url:    nodeInfo.Url,
+			State:  PeerConnecting.String(),
+			Since:  time.Now(),
+		}
+	}
+
+	return table
+}
+
+func (table *PeerTable) setState(nodeID string, state PeerState, err error) {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	status := table.peers[nodeID]
+	if status == nil {
+		return
+	}
+
+	switch state {
+	case PeerConnected:
+		if status.Attempts > 0 || status.State == PeerDisconnected.String() {
+			status.Reconnects++
+		}
+		status.Attempts = 0
+	case PeerConnecting:
+		if err != nil {
+			status.Attempts++
+		}
+	}
+	if err != nil {
+		status.LastError = err.Error()
+	}
+
+	// Log transitions only.
+	if status.State != state.String() {
+		log.Printf("peer %s (%s): %s -> %s", nodeID, status.Url, status.State, state)
+		status.State = state.String()
+		status.Since = time.Now()
+	}
+}
+
+// Snapshot of the peer states ordered by node ID.
+func (table *PeerTable) Statuses() []PeerStatus {
+	table.mutex.RLock()
+	statuses := make([]PeerStatus, 0, len(table.peers))
+	for _, status := range table.peers {
+		statuses = append(statuses, *status)
+	}
+	table.mutex.RUnlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].NodeID < statuses[j].NodeID
+	})
+
+	return statuses
+}
+
+// Exponential backoff with jitter, so peers restarting together
+// do not dial in lockstep.
+type dialBackoff struct {
+	current time.Duration
+}
+
+func (b *dialBackoff) reset() {
+	b.current = MinDialBackoff
+}
+
+func (b *dialBackoff) next() time.Duration {
+	if b.current < MinDialBackoff {
+		b.current = MinDialBackoff
+	}
+
+	// Wait between half and all of the current backoff.
+	d := b.current/2 + time.Duration(rand.Int63n(int64(b.current/2)+1))
+
+	b.current *= 2
+	if b.current > MaxDialBackoff {
+		b.current = MaxDialBackoff
+	}
+
+	return d
+}
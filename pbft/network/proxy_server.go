@@ -1,5 +1,5 @@
-// TODO: secure connection such as HTTPS, or manual implementation
-// from Section 5.2.2 Key Exchanges on TOCS.
+// Connections are secured with mutual TLS when the server is given a
+// TLS configuration (see tls.go).
 package network
 
 import (
@@ -9,17 +9,31 @@ import (
 
 	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
 	"encoding/json"
+	"fmt"
 	"log"
 	"time"
-	"crypto/ecdsa"
 )
 
+// Backoff between attempts to connect to a peer.
+const MinDialBackoff = time.Millisecond * 100
+const MaxDialBackoff = time.Second * 5
+
 type Server struct {
-	url  string
-	node *Node
+	url   string
+	node  *Node
+	peers *PeerTable
+
+	// Replica traffic goes over gRPC instead of websocket.
+	grpcTransport *grpcTransport
+
+	// Mutual TLS for all connections, if not nil.
+	tls *TLSConfig
+
+	// Loads the private key this node rotates its key to.
+	nextKey func() (consensus.Signer, error)
 }
 
-func NewServer(nodeID string, nodeTable []*NodeInfo, viewID int64, decodePrivKey *ecdsa.PrivateKey) *Server {
+func NewServer(nodeID string, nodeTable []*NodeInfo, clientTable []*ClientInfo, viewID int64, clusterID string, signer consensus.Signer) *Server {
 	nodeIdx := int(-1)
 	for idx, nodeInfo := range nodeTable {
 		if nodeInfo.NodeID == nodeID {
@@ -33,300 +47,384 @@ func NewServer(nodeID string, nodeTable []*NodeInfo, viewID int64, decodePrivKey
 		return nil
 	}
 
-	node := NewNode(nodeTable[nodeIdx], nodeTable, viewID, decodePrivKey)
-	server := &Server{nodeTable[nodeIdx].Url, node}
+	node := NewNode(nodeTable[nodeIdx], nodeTable, clientTable, viewID, clusterID, signer)
+	server := &Server{url: nodeTable[nodeIdx].Url, node: node, peers: NewPeerTable(nodeTable)}
+
+	// All consensus messages, tagged with their type, share
+	// one long-lived connection to each peer.
+	server.setRoute("/peer", node.Hub)
+
+	// Inspection for the checker.
+	http.HandleFunc("/committed", server.serveCommitted)
+
+	// Connection state of the peers.
+	http.HandleFunc("/peers", server.servePeers)
+
+	// Request submission over HTTP (see gateway.go).
+	http.HandleFunc("/req", server.serveRequest)
+
+	// Status of requests (see status.go).
+	http.HandleFunc("/status", server.serveStatus)
 
-	// Normal case.
-	server.setRoute("/req")
-	server.setRoute("/preprepare")
-	server.setRoute("/prepare")
-	server.setRoute("/commit")
-	server.setRoute("/reply")
+	// Stream of executed requests (see events.go).
+	http.HandleFunc("/events", server.serveEvents)
 
-	// View change.
-	server.setRoute("/checkpoint")
-	server.setRoute("/viewchange")
-	server.setRoute("/newview")
+	// Requests delayed by the primary (see fairness.go).
+	http.HandleFunc("/fairness", server.serveFairness)
+
+	// Key rotation of this node (see keyring.go).
+	http.HandleFunc("/rotatekey", server.serveRotateKey)
+
+	// Nonces for the signed handshakes (see auth.go).
+	http.HandleFunc("/nonce", server.serveNonce)
 
 	return server
 }
 
-func (server *Server) setRoute(path string) {
-	hub := NewHub()
+// Subscribers are authenticated before the connection is upgraded.
+func (server *Server) setRoute(path string, hub *Hub) {
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		ServeWs(hub, w, r)
+		identity, err := server.authenticate(r)
+		if err != nil {
+			log.Printf("subscription from %s rejected: %v", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		ServeWs(hub, identity, w, r)
 	}
 	http.HandleFunc(path, handler)
+}
 
-	go hub.run()
+// Serve the committed log of this node as JSON.
+func (server *Server) serveCommitted(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, server.node.getCommittedEntries())
 }
 
-func (server *Server) Start() {
-	log.Printf("Server will be started at %s...\n", server.url)
+// Serve the connection state of each peer as JSON.
+func (server *Server) servePeers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, server.peers.Statuses())
+}
 
-	go server.DialOtherNodes()
+// Request the rotation of the key of this node to its next key. Only
+// the operator of the node may, with headers signed by its key (see
+// auth.go).
+func (server *Server) serveRotateKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	identity, err := server.node.verifyHandshake("/rotatekey", r.Header.Get)
+	if err == nil && identity.NodeID != server.node.MyInfo.NodeID {
+		err = fmt.Errorf("%s may not rotate the key of %s", identity, server.node.MyInfo.NodeID)
+	}
+	if err != nil {
+		log.Printf("key rotation request from %s rejected: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if server.nextKey == nil {
+		http.Error(w, "key rotation is not configured", http.StatusNotFound)
+		return
+	}
 
-	if err := http.ListenAndServe(server.url, nil); err != nil {
-		log.Println(err)
+	next, err := server.nextKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-}
 
-func (server *Server) DialOtherNodes() {
-	// Sleep until all nodes perform ListenAndServ().
-	time.Sleep(time.Second * 3)
+	request, err := server.node.newKeyRotationRequest(next, time.Now().UnixNano())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	// Normal case.
-	var cReq = make(map[string]*websocket.Conn)
-	var cPrePrepare = make(map[string]*websocket.Conn)
-	var cPrepare = make(map[string]*websocket.Conn)
-	var cCommit = make(map[string]*websocket.Conn)
-	var cReply = make(map[string]*websocket.Conn)
+	log.Printf("Requesting key rotation of %s", server.node.MyInfo.NodeID)
+	server.node.Broadcast(request, "/req")
 
-	// View change.
-	var cCheckPoint = make(map[string]*websocket.Conn)
-	var cViewChange = make(map[string]*websocket.Conn)
-	var cNewView = make(map[string]*websocket.Conn)
+	writeJSON(w, request)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
 
+// UseGrpcTransport sends replica traffic over gRPC streams. Every node
+// in the node table must have a gRPC address.
+func (server *Server) UseGrpcTransport() error {
 	for _, nodeInfo := range server.node.NodeTable {
-		cReq[nodeInfo.NodeID] = server.setReceiveLoop("/req", nodeInfo)
-		cPrePrepare[nodeInfo.NodeID] = server.setReceiveLoop("/preprepare", nodeInfo)
-		cPrepare[nodeInfo.NodeID] = server.setReceiveLoop("/prepare", nodeInfo)
-		cCommit[nodeInfo.NodeID] = server.setReceiveLoop("/commit", nodeInfo)
-		cReply[nodeInfo.NodeID] = server.setReceiveLoop("/reply", nodeInfo)
-
-		cCheckPoint[nodeInfo.NodeID] = server.setReceiveLoop("/checkpoint", nodeInfo)
-		cViewChange[nodeInfo.NodeID] = server.setReceiveLoop("/viewchange", nodeInfo)
-		cNewView[nodeInfo.NodeID] = server.setReceiveLoop("/newview", nodeInfo)
+		if nodeInfo.GrpcUrl == "" {
+			return fmt.Errorf("node '%s' has no gRPC address", nodeInfo.NodeID)
+		}
 	}
 
-	go server.sendDummyMsg()
+	server.grpcTransport = newGrpcTransport(server.node.MyInfo, server.node.NodeTable,
+	                                        server.node.ClusterID, server.node.Signer, server.peers)
+	server.node.Transport = server.grpcTransport
 
-	// Wait.
-	select {}
+	return nil
+}
 
-	//defer c.Close()
+// UseParams replaces the default protocol parameters, e.g., with
+// those of the genesis document.
+func (server *Server) UseParams(params Params) {
+	server.node.Params = params
 }
 
-func (server *Server) setReceiveLoop(path string, nodeInfo *NodeInfo) *websocket.Conn {
-	u := url.URL{Scheme: "ws", Host: nodeInfo.Url, Path: path}
-	log.Printf("connecting to %s", u.String())
+// UseNextKey enables key rotation of this node; the private key of
+// the new key is loaded only when the rotation is requested.
+func (server *Server) UseNextKey(load func() (consensus.Signer, error)) {
+	server.nextKey = load
+}
 
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+// UseDataDir keeps the log of the executed requests in the directory
+// (see eventlog.go), so the commit stream backfills them all.
+func (server *Server) UseDataDir(dir string) error {
+	events, err := openEventLog(dir, server.node.MyInfo.NodeID)
 	if err != nil {
-		log.Fatal("dial:", err)
-		return nil
+		return err
 	}
 
-	go server.receiveLoop(c, path, nodeInfo)
+	server.node.eventLog = events
+	server.node.trimmedBefore = events.last() + 1
 
-	return c
+	return nil
 }
 
-func (server *Server) receiveLoop(c *websocket.Conn, path string, nodeInfo *NodeInfo) {
-	for {
-		_, message, err := c.ReadMessage()
-		if err != nil {
-			log.Println("read:", err)
-			return
+// UseTLS secures replica and client connections with mutual TLS.
+func (server *Server) UseTLS(config *TLSConfig) {
+	server.tls = config
+}
+
+func (server *Server) Start() {
+	log.Printf("Server will be started at %s...\n", server.url)
+
+	if server.node.MyInfo.GrpcUrl != "" {
+		go server.startGrpcServer()
+	}
+
+	if server.grpcTransport != nil {
+		server.grpcTransport.start(server.tls)
+	} else {
+		go server.DialOtherNodes()
+	}
+
+	var err error
+	if server.tls != nil {
+		httpServer := &http.Server{Addr: server.url, TLSConfig: server.tls.ServerConfig()}
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = http.ListenAndServe(server.url, nil)
+	}
+	if err != nil {
+		log.Println(err)
+		return
+	}
+}
+
+// Subscribe to every node. Nodes that are not up yet, or restart
+// later, are dialed again until they accept the connection.
+func (server *Server) DialOtherNodes() {
+	for _, nodeInfo := range server.node.NodeTable {
+		go server.setReceiveLoop("/peer", nodeInfo)
+	}
+}
+
+// Keep a connection to the given node, and dial again with jittered
+// exponential backoff whenever the connection cannot be made or is lost.
+func (server *Server) setReceiveLoop(path string, nodeInfo *NodeInfo) {
+	u := url.URL{Scheme: "ws", Host: nodeInfo.Url, Path: path}
+	dialer := websocket.DefaultDialer
+	if server.tls != nil {
+		u.Scheme = "wss"
+		dialer = &websocket.Dialer{
+			HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+			TLSClientConfig:  server.tls.DialConfig(nodeInfo.NodeID),
 		}
+	}
+	var backoff dialBackoff
 
-		var marshalledMsg []byte
-		var ok bool
-		switch path {
-		case "/req":
-			var msg consensus.RequestMsg
-			marshalledMsg, err, ok = deattachSignatureMsg(message, nodeInfo.PubKey)
-			if err != nil || ok == false {
-				break
-			}
-			_ = json.Unmarshal(marshalledMsg, &msg)
-			server.node.MsgEntrance <- &msg
-		case "/preprepare":
-			var msg consensus.PrePrepareMsg
-			marshalledMsg, err, ok = deattachSignatureMsg(message, nodeInfo.PubKey)
-			if err != nil || ok == false {
-				break
-			}
-			_ = json.Unmarshal(marshalledMsg, &msg)
-			server.node.MsgEntrance <- &msg
-		case "/prepare":
-			var msg consensus.VoteMsg
-			marshalledMsg, err, ok = deattachSignatureMsg(message, nodeInfo.PubKey)
-			if err != nil || ok == false {
-				break
-			}
-			_ = json.Unmarshal(marshalledMsg, &msg)
-			if !server.node.IsViewChanging {
-				server.node.MsgEntrance <- &msg
-			} else {
-				server.node.ViewMsgEntrance <- &msg
-			}
-		case "/commit":
-			var msg consensus.VoteMsg
-			marshalledMsg, err, ok = deattachSignatureMsg(message, nodeInfo.PubKey)
-			if err != nil || ok == false {
-				break
-			}
-			_ = json.Unmarshal(marshalledMsg, &msg)
-			if !server.node.IsViewChanging {
-				server.node.MsgEntrance <- &msg
-			} else {
-				server.node.ViewMsgEntrance <- &msg
-			}
-		case "/reply":
-			var msg consensus.ReplyMsg
-			marshalledMsg, err, ok = deattachSignatureMsg(message, nodeInfo.PubKey)
-			if err != nil || ok == false {
-				break
-			}
-			_ = json.Unmarshal(marshalledMsg, &msg)
-			if !server.node.IsViewChanging {
-				server.node.MsgEntrance <- &msg
-			} else {
-				server.node.ViewMsgEntrance <- &msg
-			}
-		case "/checkpoint":
-			var msg consensus.CheckPointMsg
-			marshalledMsg, err, ok = deattachSignatureMsg(message, nodeInfo.PubKey)
-			if err != nil || ok == false {
-				break
-			}
-			_ = json.Unmarshal(marshalledMsg, &msg)
-			server.node.MsgEntrance <- &msg
-		case "/viewchange":
-			var msg consensus.ViewChangeMsg
-			marshalledMsg, err, ok = deattachSignatureMsg(message, nodeInfo.PubKey)
-			if err != nil || ok == false {
-				break
-			}
-			_ = json.Unmarshal(marshalledMsg, &msg)
-			server.node.ViewMsgEntrance <- &msg
-		case "/newview":
-			var msg consensus.NewViewMsg
-			marshalledMsg, err, ok = deattachSignatureMsg(message, nodeInfo.PubKey)
-			if err != nil || ok == false {
-				break
-			}
-			_ = json.Unmarshal(marshalledMsg, &msg)
-			server.node.ViewMsgEntrance <- &msg
+	// Last message delivered from the node, kept across reconnections.
+	var cursor peerCursor
+
+	for {
+		// Sign the handshake anew with a nonce of the node, as each
+		// nonce is accepted once. With TLS, the certificate of this
+		// node identifies it instead.
+		var header http.Header
+		var err error
+		if server.tls == nil {
+			header, err = server.subscribeHeader(nodeInfo)
 		}
 
+		var c *websocket.Conn
+		if err == nil {
+			c, _, err = dialer.Dial(u.String(), header)
+		}
 		if err != nil {
-			log.Println(err)
+			server.peers.setState(nodeInfo.NodeID, PeerConnecting, err)
+		} else {
+			log.Printf("connected to %s", u.String())
+			server.peers.setState(nodeInfo.NodeID, PeerConnected, nil)
+			backoff.reset()
+
+			err = server.receiveLoop(c, nodeInfo, &cursor)
+			c.Close()
+			server.peers.setState(nodeInfo.NodeID, PeerDisconnected, err)
 		}
+
+		time.Sleep(backoff.next())
 	}
 }
 
-func (server *Server) sendDummyMsg() {
-	// Set periodic send signal.
-	ticker := time.NewTicker(time.Millisecond * 500)
-	defer ticker.Stop()
-
-	// Create a dummy data.
-	data := make([]byte, 1 << 20)
-	for i := range data {
-		data[i] = 'A'
+// Handshake headers for subscribing to the node, without TLS.
+func (server *Server) subscribeHeader(nodeInfo *NodeInfo) (http.Header, error) {
+	client := &http.Client{Timeout: websocket.DefaultDialer.HandshakeTimeout}
+	nonce, err := FetchNonce(client, "http://" + nodeInfo.Url)
+	if err != nil {
+		return nil, err
 	}
-	data[len(data) - 1] = 0
 
-	currentView := server.node.View.ID
+	return SubscribeHeader(server.node.ClusterID, server.node.MyInfo.NodeID, nodeInfo.NodeID, nonce, server.node.Signer)
+}
+
+// Epoch and sequence number of the last message delivered from a peer.
+type peerCursor struct {
+	epoch int64
+	seq   uint64
+}
+
+// Receive messages from the node until the connection is lost.
+func (server *Server) receiveLoop(c *websocket.Conn, nodeInfo *NodeInfo, cursor *peerCursor) error {
+	// Tell the node who we are and what we have received, so it
+	// retransmits only the messages we missed.
+	err := c.WriteJSON(&PeerMsg{
+		Type:   "/hello",
+		NodeID: server.node.MyInfo.NodeID,
+		Epoch:  cursor.epoch,
+		Seq:    cursor.seq,
+	})
+	if err != nil {
+		return err
+	}
 
-	// Current node sends dummy message when private view (currentView)
-	// is primary. (e.g., if the node index in the node table is 3,
-	// and current view ID is 1, the third dummy request message
-	// is sent from the current node.)
 	for {
-		select {
-		case <-ticker.C:
-			// Send message from the current (changed) primary node.
-			// Changing view must precedes sending request message.
-			primaryNode := server.node.getPrimaryInfoByID(currentView)
-
-			// TODO: change view based on that of the current node.
-			currentView++
-			if primaryNode.NodeID != server.node.MyInfo.NodeID {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			log.Println("read:", err)
+			return err
+		}
+
+		var peerMsg PeerMsg
+		if err = json.Unmarshal(data, &peerMsg); err != nil {
+			log.Println(err)
+			continue
+		}
+
+		// Skip retransmitted messages, and start over when the
+		// node has restarted.
+		if peerMsg.Seq != 0 {
+			if peerMsg.Epoch != cursor.epoch {
+				*cursor = peerCursor{epoch: peerMsg.Epoch}
+			}
+			if peerMsg.Seq <= cursor.seq {
 				continue
 			}
+			if cursor.seq != 0 && peerMsg.Seq != cursor.seq + 1 {
+				log.Printf("%d messages from %s are lost", peerMsg.Seq - cursor.seq - 1, nodeInfo.NodeID)
+			}
+			cursor.seq = peerMsg.Seq
 
-			// Create a dummy message.
-			u := primaryNode.Url + "/req"
-			dummy := dummyMsg("Op1", primaryNode.NodeID, data)
-
-			// Broadcast the dummy message.
-			errCh := make(chan error, 1)
-			log.Printf("Broadcasting dummy message from %s", u)
-			broadcast(errCh, u, dummy, server.node.PrivKey)
-			err := <-errCh
+			err = c.WriteJSON(&PeerMsg{Type: "/ack", Epoch: cursor.epoch, Seq: cursor.seq})
 			if err != nil {
-				log.Println(err)
+				return err
 			}
 		}
+		sigMsg, err := deattachSignatureMsg(peerMsg.Msg, server.node.ClusterID, server.node.Keys)
+		if err != nil {
+			log.Printf("%v (via %s)", err, nodeInfo.NodeID)
+			continue
+		}
+		if sigMsg.MsgType != peerMsg.Type {
+			log.Printf("%s message from %s is sent as %s", sigMsg.MsgType, sigMsg.NodeID, peerMsg.Type)
+			continue
+		}
+
+		msg := newMsgOfType(sigMsg.MsgType)
+		if msg == nil {
+			log.Printf("unknown message type %q from %s", sigMsg.MsgType, sigMsg.NodeID)
+			continue
+		}
+		if err = json.Unmarshal(sigMsg.MarshalledMsg, msg); err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if sigMsg.MsgType == "/commit" {
+			server.node.keepSignedCommit(sigMsg.NodeID, msg, &SignedCommit{Encoding: EncodingJSON, Envelope: sigMsg})
+		}
+		if err = server.node.deliverMsg(sigMsg.MsgType, sigMsg.NodeID, msg); err != nil {
+			log.Println(err)
+		}
 	}
 }
 
-func broadcast(errCh chan<- error, url string, msg []byte, privKey *ecdsa.PrivateKey) {
-	sigMgsBytes := attachSignatureMsg(msg, privKey)
-	url = "ws://" + url // Fix using url.URL{}
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
-	if err != nil {
-		errCh <- err
-		return
+// Sign the marshalled message of the given type in an envelope.
+func attachSignatureMsg(msgType string, msg []byte, clusterID string, nodeID string, signer consensus.Signer) ([]byte, error) {
+	sigMsg := &consensus.SignatureMsg{
+		Version:       consensus.ProtocolVersion,
+		ClusterID:     clusterID,
+		MsgType:       msgType,
+		NodeID:        nodeID,
+		MarshalledMsg: msg,
 	}
-
-	err = c.WriteMessage(websocket.TextMessage, sigMgsBytes)
-	if err != nil {
-		errCh <- err
-		return
+	if err := sigMsg.Sign(signer); err != nil {
+		return nil, err
 	}
-	defer c.Close()
 
-	errCh <- nil
+	return json.Marshal(sigMsg)
 }
 
-func attachSignatureMsg(msg []byte, privKey *ecdsa.PrivateKey) []byte{
-	var sigMgs consensus.SignatureMsg
-	// msg signature
-	r, s, signature, err := consensus.Sign(privKey, msg)
-	if err == nil {
-		// setting SignatureMsg
-		sigMgs = consensus.SignatureMsg {
-			Signature: signature,
-			R: r,
-			S: s,
-			MarshalledMsg: msg,
-		}
+// Unmarshal the envelope and verify it.
+func deattachSignatureMsg(msg []byte, clusterID string, keys *KeyRing) (*consensus.SignatureMsg, error) {
+	var sigMsg consensus.SignatureMsg
+	if err := json.Unmarshal(msg, &sigMsg); err != nil {
+		return nil, err
+	}
+	if err := checkSignatureMsg(&sigMsg, clusterID, keys); err != nil {
+		return nil, err
 	}
-	sigMgsBytes, _ := json.Marshal(sigMgs)
 
-	return sigMgsBytes
+	return &sigMsg, nil
 }
 
-func deattachSignatureMsg(msg []byte, pubkey *ecdsa.PublicKey) ([]byte, error, bool) {
-	var sigMgs consensus.SignatureMsg
-	// unmarshal sigmsgs
-	err := json.Unmarshal(msg, &sigMgs)
-	if err != nil {
-		return nil, err, false
+// Verify the envelope with the public keys of the node named as its
+// sender, and check it is meant for this protocol version and cluster.
+func checkSignatureMsg(sigMsg *consensus.SignatureMsg, clusterID string, keys *KeyRing) error {
+	if sigMsg.Version != consensus.ProtocolVersion {
+		return fmt.Errorf("message from %s has protocol version %d", sigMsg.NodeID, sigMsg.Version)
+	}
+	if sigMsg.ClusterID != clusterID {
+		return fmt.Errorf("message from %s is for cluster %q", sigMsg.NodeID, sigMsg.ClusterID)
 	}
-	// msg VerifySignature
-	ok := consensus.Verify(pubkey, sigMgs.R, sigMgs.S, sigMgs.MarshalledMsg)
-	return sigMgs.MarshalledMsg, nil, ok
-}
-
-func dummyMsg(operation string, clientID string, data []byte) []byte {
-	var msg consensus.RequestMsg
-	msg.Operation = operation
-	msg.ClientID = clientID
-	msg.Data = string(data)
-	msg.Timestamp = time.Now().UnixNano()
 
-	// {"operation": "Op1", "clientID": "Client1", "data": "JJWEJPQOWJE", "timestamp": 190283901}
-	jsonMsg, err := json.Marshal(&msg)
-	if err != nil {
-		log.Println(err)
-		return nil
+	verifier := keys.Verifier(sigMsg.NodeID)
+	if verifier == nil {
+		return fmt.Errorf("message signed by unknown node %q", sigMsg.NodeID)
+	}
+	if !sigMsg.Verify(verifier) {
+		return fmt.Errorf("invalid signature on %s message from %s", sigMsg.MsgType, sigMsg.NodeID)
 	}
 
-	return []byte(jsonMsg)
+	return nil
+}
+
+// OpenSignatureMsg verifies a message signed by a replica, e.g., a
+// reply received by a client from the hub of the replica.
+func OpenSignatureMsg(msg []byte, clusterID string, keys *KeyRing) (*consensus.SignatureMsg, error) {
+	return deattachSignatureMsg(msg, clusterID, keys)
 }
@@ -0,0 +1,330 @@
+// Ordering of client requests, and detection of a primary that does
+// not order them.
+//
+// From TOCS: If the client does not receive replies soon enough, it
+// broadcasts the request to all replicas. If the request has not been
+// executed, a backup relays the request to the primary. If the primary
+// does not multicast the request to the group, it will eventually be
+// suspected to be faulty by enough replicas to cause a view change.
+//
+// Requests are relayed to all replicas, including the primary, where
+// they enter the cluster (the gateway, the Submit RPC, and key
+// rotations). Only the primary assigns them sequence numbers; the
+// backups learn them from the PRE-PREPARE messages, which carry the
+// request, so a backup need not wait for the request to be relayed to
+// it. A PRE-PREPARE carrying a request the backup has executed, or
+// will not accept, is dropped.
+//
+// From TOCS: A backup starts a timer when it receives a request and
+// the timer is not already running. It stops the timer when it is no
+// longer waiting to execute the request, but restarts it if at that
+// point it is waiting to execute some other request. A backup votes
+// for a view change when the timer expires, as the primary has stopped
+// ordering. A primary which orders some requests and ignores others is
+// detected by the backups as it delays them (see fairness.go).
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Request accepted by this node and not executed yet.
+type pendingRequest struct {
+	request     *consensus.RequestMsg
+	received    time.Time
+	ordered     bool        // by this node as the primary
+	orderedAt   time.Time   // zero until the primary orders it
+	overtakenBy int         // later requests ordered first (see fairness.go)
+	reported    bool        // as delayed
+}
+
+// Keep the request until it is executed, and start the request timer
+// on the backups.
+func (node *Node) addPendingRequest(reqMsg *consensus.RequestMsg) {
+	key := fmt.Sprintf("%s/%d", reqMsg.ClientID, reqMsg.Timestamp)
+
+	node.pendingRequestsMutex.Lock()
+	if _, ok := node.pendingRequests[key]; ok {
+		node.pendingRequestsMutex.Unlock()
+		return
+	}
+	node.pendingRequests[key] = &pendingRequest{request: reqMsg, received: time.Now()}
+
+	if node.requestTimer == nil && !node.isMyNodePrimary() {
+		node.startRequestTimer()
+	}
+	node.pendingRequestsMutex.Unlock()
+}
+
+// Must be called with pendingRequestsMutex held.
+func (node *Node) startRequestTimer() {
+	var timer *time.Timer
+	timer = time.AfterFunc(node.Params.requestTimeout(), func() {
+		node.pendingRequestsMutex.Lock()
+		current := node.requestTimer
+		var oldest *pendingRequest
+		for _, pending := range node.pendingRequests {
+			if oldest == nil || pending.received.Before(oldest.received) {
+				oldest = pending
+			}
+		}
+		node.pendingRequestsMutex.Unlock()
+
+		// Stopped, or restarted at an execution or in a new view.
+		if current != timer || oldest == nil {
+			return
+		}
+
+		node.suspectPrimary(fmt.Errorf("request %s/%d is not executed within %v in view %d",
+		                               oldest.request.ClientID, oldest.request.Timestamp,
+		                               node.Params.requestTimeout(), node.View.ID))
+	})
+	node.requestTimer = timer
+}
+
+// Must be called with pendingRequestsMutex held.
+func (node *Node) stopRequestTimer() {
+	if node.requestTimer != nil {
+		node.requestTimer.Stop()
+		node.requestTimer = nil
+	}
+}
+
+// Forget the executed request, and restart the request timer if other
+// requests are still waiting.
+func (node *Node) removePendingRequest(reqMsg *consensus.RequestMsg) {
+	key := fmt.Sprintf("%s/%d", reqMsg.ClientID, reqMsg.Timestamp)
+
+	node.pendingRequestsMutex.Lock()
+	defer node.pendingRequestsMutex.Unlock()
+
+	if _, ok := node.pendingRequests[key]; !ok {
+		return
+	}
+	delete(node.pendingRequests, key)
+
+	node.stopRequestTimer()
+	if len(node.pendingRequests) > 0 && !node.isMyNodePrimary() {
+		node.startRequestTimer()
+	}
+}
+
+// Vote for a view change, as the primary appears to be faulty.
+func (node *Node) suspectPrimary(err error) {
+	if node.IsViewChanging {
+		return
+	}
+
+	node.IsViewChanging = true
+	node.MsgError <- []error{err}
+	node.StartViewChange()
+}
+
+// Order the pending requests at the primary in the order they were
+// received, while fewer than maxOrderedRequests are not executed yet
+// (see admission.go).
+func (node *Node) orderPendingRequests() {
+	if !node.isMyNodePrimary() {
+		return
+	}
+
+	node.pendingRequestsMutex.Lock()
+	ordered := 0
+	var waiting []*pendingRequest
+	for _, pending := range node.pendingRequests {
+		if pending.ordered {
+			ordered++
+		} else {
+			waiting = append(waiting, pending)
+		}
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		return waiting[i].received.Before(waiting[j].received)
+	})
+	if free := maxOrderedRequests - ordered; len(waiting) > free {
+		if free < 0 {
+			free = 0
+		}
+		waiting = waiting[:free]
+	}
+	now := time.Now()
+	for _, pending := range waiting {
+		pending.ordered = true
+		pending.orderedAt = now
+	}
+	node.pendingRequestsMutex.Unlock()
+
+	for _, pending := range waiting {
+		node.startConsensus(pending.request)
+	}
+}
+
+// From TOCS: The primary picks the ordering for execution of the
+// request, and multicasts it to the backups with a PRE-PREPARE message.
+func (node *Node) startConsensus(reqMsg *consensus.RequestMsg) {
+	// Create a new state object.
+	state := node.createState(reqMsg.Timestamp)
+
+	// Increment the number of request message atomically.
+	// TODO: Currently, StartConsensus must succeed.
+	newTotalConsensus := atomic.AddInt64(&node.TotalConsensus, 1)
+	prePrepareMsg := state.StartConsensus(reqMsg, newTotalConsensus)
+
+	// Register state into node and update last sequence number.
+	node.StatesMutex.Lock()
+	node.States[prePrepareMsg.SequenceID] = state
+	node.StatesMutex.Unlock()
+
+	fmt.Printf("Consensus Process (ViewID: %d, SequenceID: %d)\n",
+	           prePrepareMsg.ViewID, prePrepareMsg.SequenceID)
+
+	// Broadcast PrePrepare message.
+	LogStage("Request", true)
+	node.Broadcast(prePrepareMsg, "/preprepare")
+	LogStage("Pre-prepare", false)
+
+	// Deadline is determined by the timestamp of the current node.
+	go node.startTransitionWithDeadline(state, time.Now().UnixNano())
+}
+
+// Get the state of the PRE-PREPARE message, or create it on a backup
+// with the request the message carries. Returns no state and no error
+// for a message to be dropped.
+func (node *Node) getPrePrepareState(prePrepareMsg *consensus.PrePrepareMsg) (consensus.PBFT, error) {
+	if state, _ := node.getState(prePrepareMsg.SequenceID); state != nil {
+		return state, nil
+	}
+
+	if prePrepareMsg.ViewID < node.View.ID {
+		node.MsgError <- []error{fmt.Errorf("pre-prepare message for sequence number %d is of old view %d",
+		                                    prePrepareMsg.SequenceID, prePrepareMsg.ViewID)}
+		return nil, nil
+	}
+	if prePrepareMsg.ViewID > node.View.ID {
+		return nil, fmt.Errorf("State for sequence number %d has not created yet.", prePrepareMsg.SequenceID)
+	}
+
+	// The request must be signed by its client, so the primary cannot
+	// order a request of its own making.
+	if prePrepareMsg.RequestMsg == nil {
+		node.MsgError <- []error{fmt.Errorf("pre-prepare message for sequence number %d carries no request",
+		                                    prePrepareMsg.SequenceID)}
+		return nil, nil
+	}
+	if err := node.verifyRequest(prePrepareMsg.RequestMsg); err != nil {
+		node.MsgError <- []error{fmt.Errorf("pre-prepare message for sequence number %d: %v",
+		                                    prePrepareMsg.SequenceID, err)}
+		return nil, nil
+	}
+
+	// Accept the request as if relayed to this node, unless it has
+	// been executed or superseded by a later request of its client.
+	request := *prePrepareMsg.RequestMsg
+	request.SequenceID = prePrepareMsg.SequenceID
+	if !node.isPending(&request) {
+		if !node.acceptRequest(&request) {
+			node.MsgError <- []error{fmt.Errorf("pre-prepare message for sequence number %d names request %s/%d, which is not pending",
+			                                    prePrepareMsg.SequenceID, request.ClientID, request.Timestamp)}
+			return nil, nil
+		}
+		node.addPendingRequest(&request)
+	}
+
+	// The digest of the request with the sequence number must match
+	// that in the message (see State.PrePrepare), so the primary
+	// cannot order one request under the digest of another.
+	node.StatesMutex.Lock()
+	state := node.States[prePrepareMsg.SequenceID]
+	if state != nil {
+		node.StatesMutex.Unlock()
+		return state, nil
+	}
+	state = node.createState(request.Timestamp)
+	state.SetSequenceID(prePrepareMsg.SequenceID)
+	state.SetReqMsg(&request)
+	state.SetDigest(request.Digest())
+	node.States[prePrepareMsg.SequenceID] = state
+	node.StatesMutex.Unlock()
+
+	node.markOrdered(&request)
+
+	// Continue after the sequence numbers of the primary if this node
+	// becomes the primary.
+	for {
+		total := atomic.LoadInt64(&node.TotalConsensus)
+		if total >= prePrepareMsg.SequenceID ||
+		   atomic.CompareAndSwapInt64(&node.TotalConsensus, total, prePrepareMsg.SequenceID) {
+			break
+		}
+	}
+
+	// From TOCS: The backups check the sequence numbers assigned by
+	// the primary and use timeouts to detect when it stops.
+	// They trigger view changes to select a new primary when it
+	// appears that the current one has failed.
+	go node.startTransitionWithDeadline(state, time.Now().UnixNano())
+
+	return state, nil
+}
+
+// Check the request is accepted by this node and not executed yet.
+func (node *Node) isPending(reqMsg *consensus.RequestMsg) bool {
+	key := fmt.Sprintf("%s/%d", reqMsg.ClientID, reqMsg.Timestamp)
+
+	node.pendingRequestsMutex.Lock()
+	defer node.pendingRequestsMutex.Unlock()
+
+	return node.pendingRequests[key] != nil
+}
+
+// From TOCS: Backups restart their timers in the new view, and the new
+// primary orders the requests that are still waiting, unless they have
+// been pre-prepared already.
+func (node *Node) resumePendingRequests() {
+	node.pendingRequestsMutex.Lock()
+	node.stopRequestTimer()
+	if len(node.pendingRequests) > 0 && !node.isMyNodePrimary() {
+		node.startRequestTimer()
+	}
+	pendings := make([]*pendingRequest, 0, len(node.pendingRequests))
+	for _, pending := range node.pendingRequests {
+		pendings = append(pendings, pending)
+	}
+	node.pendingRequestsMutex.Unlock()
+
+	if !node.isMyNodePrimary() {
+		return
+	}
+
+	ordered := make([]bool, len(pendings))
+	for i, pending := range pendings {
+		ordered[i] = node.isOrdered(pending.request)
+	}
+	node.pendingRequestsMutex.Lock()
+	for i, pending := range pendings {
+		pending.ordered = ordered[i]
+	}
+	node.pendingRequestsMutex.Unlock()
+
+	node.orderPendingRequests()
+}
+
+// Check a state exists for the request.
+func (node *Node) isOrdered(reqMsg *consensus.RequestMsg) bool {
+	node.StatesMutex.RLock()
+	defer node.StatesMutex.RUnlock()
+
+	for _, state := range node.States {
+		request := state.GetReqMsg()
+		if request != nil && request.ClientID == reqMsg.ClientID && request.Timestamp == reqMsg.Timestamp {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,161 @@
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"testing"
+)
+
+// Backup Node2 of view 0, without requests, and the signer of its
+// client Client1.
+func newBackupNode(t *testing.T) (*Node, consensus.Signer) {
+	client := newSigner(t)
+	node := newDeliveryNode()
+	node.MyInfo = node.NodeTable[1]
+	node.View = &View{ID: 0, Primary: node.NodeTable[0]}
+	node.Params = Params{ConsensusDeadlineMs: 60000, RequestTimeoutMs: 60000}
+	node.ClusterID = "test"
+	node.ClientTable = []*ClientInfo{{ClientID: "Client1", PubKey: client.Verifier()}}
+	node.States = make(map[int64]consensus.PBFT)
+	node.lastRequests = make(map[string]int64)
+	node.pendingRequests = make(map[string]*pendingRequest)
+	node.MsgError = make(chan []error, 1)
+
+	return node, client
+}
+
+// Request of Client1 signed by the given signer.
+func signedRequest(t *testing.T, signer consensus.Signer, timestamp int64, data string) *consensus.RequestMsg {
+	request := &consensus.RequestMsg{ClientID: "Client1", Timestamp: timestamp, Operation: "Put", Data: data}
+	if err := request.Sign("test", signer); err != nil {
+		t.Fatal(err)
+	}
+
+	return request
+}
+
+// Pre-prepare message of the primary of view 0 for the request.
+func prePrepareOf(request *consensus.RequestMsg, sequenceID int64) *consensus.PrePrepareMsg {
+	ordered := *request
+	ordered.SequenceID = sequenceID
+
+	return &consensus.PrePrepareMsg{ViewID: 0, SequenceID: sequenceID, Digest: ordered.Digest(), RequestMsg: &ordered}
+}
+
+func TestRequestDigestLeavesOutSignature(t *testing.T) {
+	request := &consensus.RequestMsg{ClientID: "Client1", Timestamp: 1, Operation: "Put", Data: "k=1"}
+	digest := request.Digest()
+
+	request.Signature = []byte("signature")
+	if request.Digest() != digest {
+		t.Errorf("digest of the request covers its signature")
+	}
+	request.Data = "k=2"
+	if request.Digest() == digest {
+		t.Errorf("digest of the request does not cover its data")
+	}
+}
+
+func TestPrePrepareCarriesRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		relayed string // data of the copy relayed to the backup, if any
+	}{
+		{"not relayed", ""},
+		{"same copy relayed", "k=1"},
+		{"other copy relayed", "k=2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, client := newBackupNode(t)
+			if test.relayed != "" {
+				relayed := signedRequest(t, client, 1, test.relayed)
+				node.acceptRequest(relayed)
+				node.addPendingRequest(relayed)
+			}
+
+			// The backup orders the copy of the primary.
+			prePrepareMsg := prePrepareOf(signedRequest(t, client, 1, "k=1"), 1)
+			state, err := node.getPrePrepareState(prePrepareMsg)
+			if state == nil || err != nil {
+				t.Fatalf("PRE-PREPARE is not resolved: %v, %v", state, err)
+			}
+			if state.GetDigest() != prePrepareMsg.Digest || state.GetReqMsg().Data != "k=1" {
+				t.Errorf("state orders %q with digest %s, want the request of the primary", state.GetReqMsg().Data, state.GetDigest())
+			}
+			if len(node.pendingRequests) != 1 {
+				t.Errorf("%d requests are pending, want 1", len(node.pendingRequests))
+			}
+		})
+	}
+}
+
+func TestPrePrepareOfRejectedRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		carried   bool
+		timestamp int64
+		forged    bool
+	}{
+		{"no request", false, 2, false},
+		{"forged request", true, 2, true},
+		{"executed request", true, 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, client := newBackupNode(t)
+			node.lastRequests["Client1"] = 1
+
+			prePrepareMsg := prePrepareOf(signedRequest(t, client, test.timestamp, "k=1"), 1)
+			if !test.carried {
+				prePrepareMsg.RequestMsg = nil
+			}
+			if test.forged {
+				prePrepareMsg.RequestMsg.Data = "k=2"
+				prePrepareMsg.Digest = prePrepareMsg.RequestMsg.Digest()
+			}
+
+			state, err := node.getPrePrepareState(prePrepareMsg)
+			if state != nil || err != nil {
+				t.Fatalf("PRE-PREPARE of a rejected request is resolved: %v, %v", state, err)
+			}
+			<-node.MsgError
+			if len(node.States) != 0 || len(node.pendingRequests) != 0 {
+				t.Errorf("PRE-PREPARE of a rejected request is kept")
+			}
+		})
+	}
+}
+
+func TestRequestTimer(t *testing.T) {
+	node, _ := newBackupNode(t)
+	requests := []*consensus.RequestMsg{
+		{ClientID: "Client1", Timestamp: 1},
+		{ClientID: "Client2", Timestamp: 1},
+	}
+
+	for _, reqMsg := range requests {
+		node.addPendingRequest(reqMsg)
+	}
+	timer := node.requestTimer
+	if timer == nil {
+		t.Fatalf("request timer is not started")
+	}
+	node.addPendingRequest(&consensus.RequestMsg{ClientID: "Client3", Timestamp: 1})
+	if node.requestTimer != timer {
+		t.Errorf("running request timer is restarted by a new request")
+	}
+
+	// Restarted while requests are still waiting.
+	node.removePendingRequest(requests[0])
+	if node.requestTimer == nil || node.requestTimer == timer {
+		t.Errorf("request timer is not restarted at an execution")
+	}
+
+	node.removePendingRequest(requests[1])
+	node.removePendingRequest(&consensus.RequestMsg{ClientID: "Client3", Timestamp: 1})
+	if node.requestTimer != nil {
+		t.Errorf("request timer runs without pending requests")
+	}
+}
@@ -0,0 +1,202 @@
+// Status of requests at this replica.
+//
+// GET /status with the "client" and "timestamp" query parameters, or
+// with the "digest" parameter, returns the stage the request reached
+// at this replica, with its sequence number and view once ordered, and
+// its result once executed. The digest is that of the ordered request,
+// as in the PRE-PREPARE message and the committed log (see /committed).
+//
+//   curl 'http://localhost:1112/status?client=Client1&timestamp=1792329243746565658'
+
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// Stages of a request at a replica.
+const StatusUnknown = "unknown"
+const StatusPending = "pending" // accepted, not ordered yet
+const StatusPrePrepared = "pre-prepared"
+const StatusPrepared = "prepared"
+const StatusCommitted = "committed"
+const StatusExecuted = "executed"
+
+// Response of the status query.
+type RequestStatus struct {
+	NodeID     string `json:"nodeID"`
+	ClientID   string `json:"clientID,omitempty"`
+	Timestamp  int64  `json:"timestamp,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	Status     string `json:"status"`
+	SequenceID int64  `json:"sequenceID,omitempty"`
+	ViewID     int64  `json:"viewID,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Request executed by this node, with its reply and the COMMIT
+// messages it was committed with.
+type executedRequest struct {
+	request     *consensus.RequestMsg
+	digest      string
+	reply       *consensus.ReplyMsg
+	certificate []*SignedCommit
+}
+
+func (server *Server) serveStatus(w http.ResponseWriter, r *http.Request) {
+	status := &RequestStatus{NodeID: server.node.MyInfo.NodeID, Status: StatusUnknown}
+
+	if r.Method != http.MethodGet {
+		status.Error = "GET the status of a request"
+		writeJSONStatus(w, http.StatusMethodNotAllowed, status)
+		return
+	}
+
+	query := r.URL.Query()
+	if digest := query.Get("digest"); digest != "" {
+		status.Digest = digest
+	} else {
+		status.ClientID = query.Get("client")
+		timestamp, err := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+		if status.ClientID == "" || err != nil {
+			status.Error = "query by client and timestamp, or by digest"
+			writeJSONStatus(w, http.StatusBadRequest, status)
+			return
+		}
+		status.Timestamp = timestamp
+	}
+
+	server.node.requestStatus(status)
+	if status.Status == StatusUnknown {
+		writeJSONStatus(w, http.StatusNotFound, status)
+		return
+	}
+
+	writeJSONStatus(w, http.StatusOK, status)
+}
+
+// Fill the status of the request named by its client and timestamp,
+// or by its digest.
+func (node *Node) requestStatus(status *RequestStatus) {
+	key := fmt.Sprintf("%s/%d", status.ClientID, status.Timestamp)
+	matches := func(request *consensus.RequestMsg, digest string) bool {
+		if status.Digest != "" {
+			return digest == status.Digest
+		}
+		return request != nil && request.ClientID == status.ClientID && request.Timestamp == status.Timestamp
+	}
+
+	// Executed.
+	node.executedRequestsMutex.RLock()
+	executed := node.executedRequests[key]
+	if status.Digest != "" {
+		executed = node.executedDigests[status.Digest]
+	}
+	node.executedRequestsMutex.RUnlock()
+
+	if executed != nil {
+		status.fill(executed.request, executed.digest)
+		status.Status = StatusExecuted
+		status.SequenceID = executed.request.SequenceID
+		status.ViewID = executed.reply.ViewID
+		status.Result = executed.reply.Result
+		return
+	}
+
+	// Ordered. A request may be ordered again in a later view, so
+	// the highest sequence number is the one to execute.
+	var found consensus.PBFT
+	node.StatesMutex.RLock()
+	for _, state := range node.States {
+		if !matches(state.GetReqMsg(), state.GetDigest()) {
+			continue
+		}
+		if found == nil || state.GetSequenceID() > found.GetSequenceID() {
+			found = state
+		}
+	}
+	node.StatesMutex.RUnlock()
+
+	if found != nil {
+		status.fill(found.GetReqMsg(), found.GetDigest())
+		status.SequenceID = found.GetSequenceID()
+		if prePrepareMsg := found.GetPrePrepareMsg(); prePrepareMsg != nil {
+			status.ViewID = prePrepareMsg.ViewID
+		}
+
+		switch found.GetStage() {
+		case consensus.Idle:
+			status.Status = StatusPending
+		case consensus.PrePrepared:
+			status.Status = StatusPrePrepared
+		case consensus.Prepared:
+			status.Status = StatusPrepared
+		case consensus.Committed:
+			status.Status = StatusCommitted
+		}
+		return
+	}
+
+	// Accepted. Its digest is not known before it is ordered.
+	if status.Digest != "" {
+		return
+	}
+	node.pendingRequestsMutex.Lock()
+	pending := node.pendingRequests[key]
+	node.pendingRequestsMutex.Unlock()
+
+	if pending != nil {
+		status.Status = StatusPending
+	}
+}
+
+func (status *RequestStatus) fill(request *consensus.RequestMsg, digest string) {
+	status.Digest = digest
+	if request != nil {
+		status.ClientID = request.ClientID
+		status.Timestamp = request.Timestamp
+	}
+}
+
+// Index the executed request with its reply, and log it for the
+// commit stream.
+func (node *Node) recordExecution(pair *MsgPair) {
+	executed := &executedRequest{
+		request: pair.committedMsg,
+		digest:  pair.committedMsg.Digest(),
+		reply:   pair.replyMsg,
+	}
+	if state, _ := node.getState(pair.committedMsg.SequenceID); state != nil {
+		// Votes of earlier views are cleared at the view change.
+		var commitMsgs []*consensus.VoteMsg
+		for _, commitMsg := range state.GetCommitMsgs() {
+			if commitMsg != nil && commitMsg.ViewID == pair.replyMsg.ViewID && commitMsg.Digest == executed.digest {
+				commitMsgs = append(commitMsgs, commitMsg)
+			}
+		}
+		sort.Slice(commitMsgs, func(i, j int) bool {
+			return commitMsgs[i].NodeID < commitMsgs[j].NodeID
+		})
+		executed.certificate = node.signedCertificate(pair.committedMsg.SequenceID, commitMsgs)
+	}
+	key := fmt.Sprintf("%s/%d", pair.committedMsg.ClientID, pair.committedMsg.Timestamp)
+
+	if node.eventLog != nil {
+		if err := node.eventLog.append(node.commitEvent(executed)); err != nil {
+			node.MsgError <- []error{fmt.Errorf("event %d is not logged: %v", pair.committedMsg.SequenceID, err)}
+		}
+	}
+
+	node.executedRequestsMutex.Lock()
+	node.executedRequests[key] = executed
+	node.executedDigests[executed.digest] = executed
+	node.executedLog = append(node.executedLog, executed)
+	close(node.executedSignal)
+	node.executedSignal = make(chan struct{})
+	node.executedRequestsMutex.Unlock()
+}
@@ -0,0 +1,250 @@
+// Mutual TLS between replicas and for client endpoints.
+//
+// Each replica has a self-signed certificate whose common name is its
+// node ID. Certificates of all replicas are pinned: a replica is only
+// accepted if it presents exactly the certificate listed for its node
+// ID. Clients present certificates signed by a separate client CA,
+// and their common name is their client ID, which must be in the
+// client table. A certificate of the client CA never identifies a
+// replica, nor a client the replicas do not know.
+
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type TLSConfig struct {
+	// Certificate and private key presented by this node.
+	Cert tls.Certificate
+
+	// Pinned certificates of the replicas. key: nodeID
+	PeerCerts map[string]*x509.Certificate
+
+	// CA signing client certificates.
+	ClientCAs *x509.CertPool
+
+	// Clients of the client table. key: clientID
+	ClientIDs map[string]bool
+}
+
+// LoadTLSConfig reads, from dir, the certificate and key of the given
+// node (<nodeID>.crt, <nodeID>.key), the certificates of all nodes in
+// the node table, and the client CA certificate (client-ca.crt). Only
+// the clients of the client table are accepted.
+func LoadTLSConfig(dir string, nodeID string, nodeTable []*NodeInfo, clientTable []*ClientInfo) (*TLSConfig, error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, nodeID + ".crt"), filepath.Join(dir, nodeID + ".key"))
+	if err != nil {
+		return nil, fmt.Errorf("certificate of %s: %v", nodeID, err)
+	}
+
+	config := &TLSConfig{
+		Cert:      cert,
+		PeerCerts: make(map[string]*x509.Certificate),
+		ClientCAs: x509.NewCertPool(),
+		ClientIDs: make(map[string]bool),
+	}
+
+	for _, nodeInfo := range nodeTable {
+		peerCert, err := loadCertificate(filepath.Join(dir, nodeInfo.NodeID + ".crt"))
+		if err != nil {
+			return nil, err
+		}
+		if peerCert.Subject.CommonName != nodeInfo.NodeID {
+			return nil, fmt.Errorf("certificate of %s is issued to %q",
+			                       nodeInfo.NodeID, peerCert.Subject.CommonName)
+		}
+		config.PeerCerts[nodeInfo.NodeID] = peerCert
+	}
+
+	caCert, err := loadCertificate(filepath.Join(dir, "client-ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs.AddCert(caCert)
+
+	for _, clientInfo := range clientTable {
+		config.ClientIDs[clientInfo.ClientID] = true
+	}
+
+	return config, nil
+}
+
+func loadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s: no PEM encoded certificate", path)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return cert, nil
+}
+
+// Identity of the remote end of a connection (see also auth.go).
+// Exactly one of NodeID and ClientID is set.
+type PeerIdentity struct {
+	NodeID   string
+	ClientID string
+}
+
+func (identity *PeerIdentity) String() string {
+	if identity.NodeID != "" {
+		return "replica " + identity.NodeID
+	}
+
+	return "client " + identity.ClientID
+}
+
+// Identify a certificate chain presented by the remote end.
+func (config *TLSConfig) identify(rawCerts [][]byte) (*PeerIdentity, error) {
+	if len(rawCerts) == 0 {
+		return nil, errors.New("no certificate presented")
+	}
+
+	leaf, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// Replicas present their pinned certificate.
+	commonName := leaf.Subject.CommonName
+	if pinned, ok := config.PeerCerts[commonName]; ok {
+		if !pinned.Equal(leaf) {
+			return nil, fmt.Errorf("certificate of replica %s is not the pinned one", commonName)
+		}
+		return &PeerIdentity{NodeID: commonName}, nil
+	}
+
+	// Clients present a certificate signed by the client CA.
+	intermediates := x509.NewCertPool()
+	for _, raw := range rawCerts[1:] {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, err
+		}
+		intermediates.AddCert(cert)
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         config.ClientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("certificate of %q is neither pinned nor signed by the client CA: %v",
+		                       commonName, err)
+	}
+	if !config.ClientIDs[commonName] {
+		return nil, fmt.Errorf("certificate of %q is not issued to a client of the client table", commonName)
+	}
+
+	return &PeerIdentity{ClientID: commonName}, nil
+}
+
+// Identity of the remote end of an established connection.
+func (config *TLSConfig) IdentifyConn(state *tls.ConnectionState) (*PeerIdentity, error) {
+	if state == nil {
+		return nil, errors.New("not a TLS connection")
+	}
+
+	rawCerts := make([][]byte, len(state.PeerCertificates))
+	for i, cert := range state.PeerCertificates {
+		rawCerts[i] = cert.Raw
+	}
+
+	return config.identify(rawCerts)
+}
+
+// TLS configuration for the listener of this node. Both replicas and
+// clients must present a certificate.
+func (config *TLSConfig) ServerConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{config.Cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		MinVersion:   tls.VersionTLS13,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			_, err := config.identify(rawCerts)
+			return err
+		},
+	}
+}
+
+// TLS configuration for dialing the given node. The chain is not
+// verified against a CA; the node must present its pinned certificate.
+func (config *TLSConfig) DialConfig(nodeID string) *tls.Config {
+	return &tls.Config{
+		Certificates:       []tls.Certificate{config.Cert},
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			pinned, ok := config.PeerCerts[nodeID]
+			if !ok {
+				return fmt.Errorf("no pinned certificate for %s", nodeID)
+			}
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("%s presented no certificate", nodeID)
+			}
+			leaf, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			if !pinned.Equal(leaf) {
+				return fmt.Errorf("%s presented a certificate other than the pinned one", nodeID)
+			}
+			return nil
+		},
+	}
+}
+
+// LoadClientTLSConfig reads the client certificate and key from dir
+// (client.crt, client.key) and the certificates of all nodes, for
+// tools connecting to the replicas as a client.
+func LoadClientTLSConfig(dir string, nodeTable []*NodeInfo) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	if err != nil {
+		return nil, fmt.Errorf("client certificate: %v", err)
+	}
+
+	pinned := make(map[string]*x509.Certificate)
+	for _, nodeInfo := range nodeTable {
+		peerCert, err := loadCertificate(filepath.Join(dir, nodeInfo.NodeID + ".crt"))
+		if err != nil {
+			return nil, err
+		}
+		pinned[nodeInfo.NodeID] = peerCert
+	}
+
+	return &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("replica presented no certificate")
+			}
+			leaf, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			if peerCert, ok := pinned[leaf.Subject.CommonName]; !ok || !peerCert.Equal(leaf) {
+				return fmt.Errorf("certificate of %q is not pinned", leaf.Subject.CommonName)
+			}
+			return nil
+		},
+	}, nil
+}
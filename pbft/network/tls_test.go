@@ -0,0 +1,102 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// Create a certificate for the common name, signed by the parent or,
+// without a parent, self-signed.
+func newCertificate(t *testing.T, commonName string, isCA bool,
+                    parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if isCA {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func TestIdentify(t *testing.T) {
+	caCert, caKey := newCertificate(t, "PBFT Client CA", true, nil, nil)
+	node1Cert, _ := newCertificate(t, "Node1", false, nil, nil)
+
+	config := &TLSConfig{
+		PeerCerts: map[string]*x509.Certificate{"Node1": node1Cert},
+		ClientCAs: x509.NewCertPool(),
+		ClientIDs: map[string]bool{"Client1": true},
+	}
+	config.ClientCAs.AddCert(caCert)
+
+	issue := func(commonName string) *x509.Certificate {
+		cert, _ := newCertificate(t, commonName, false, caCert, caKey)
+		return cert
+	}
+	selfSigned := func(commonName string) *x509.Certificate {
+		cert, _ := newCertificate(t, commonName, false, nil, nil)
+		return cert
+	}
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		identity string // empty if rejected
+	}{
+		{"pinned replica", node1Cert, "replica Node1"},
+		{"client of the client table", issue("Client1"), "client Client1"},
+		{"client CA certificate of a replica", issue("Node1"), ""},
+		{"client missing from the client table", issue("Client2"), ""},
+		{"client CA certificate without common name", issue(""), ""},
+		{"replica not pinned", selfSigned("Node1"), ""},
+		{"client not signed by the client CA", selfSigned("Client1"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := config.identify([][]byte{tt.cert.Raw})
+			if tt.identity == "" {
+				if err == nil {
+					t.Fatalf("accepted as %s", identity)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.String() != tt.identity {
+				t.Fatalf("identified as %s, want %s", identity, tt.identity)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"encoding/json"
+)
+
+// Transport delivers consensus messages among the nodes.
+type Transport interface {
+	// Broadcast msg of the given type (e.g., "/prepare") to all
+	// nodes, including the sending node itself.
+	Broadcast(msgType string, msg interface{}) error
+
+	// Send msg of the given type to the given node only.
+	Send(nodeID string, msgType string, msg interface{}) error
+}
+
+// Messages are signed as JSON and published on the hub, which queues
+// them on the websocket connection of every subscribed peer.
+type wsTransport struct {
+	hub       *Hub
+	clusterID string
+	nodeID    string
+	signer    consensus.Signer
+}
+
+func (t *wsTransport) Broadcast(msgType string, msg interface{}) error {
+	return t.publish("", msgType, msg)
+}
+
+func (t *wsTransport) Send(nodeID string, msgType string, msg interface{}) error {
+	return t.publish(nodeID, msgType, msg)
+}
+
+// Publish the message on the hub for the given subscriber, or for all
+// replicas if to is empty.
+func (t *wsTransport) publish(to string, msgType string, msg interface{}) error {
+	peerMsg, err := signPeerMsg(msgType, msg, t.clusterID, t.nodeID, t.signer)
+	if err != nil {
+		return err
+	}
+	peerMsg.to = to
+
+	t.hub.broadcast <- peerMsg
+
+	return nil
+}
+
+// Sign the message of the given type into a message for the hub.
+func signPeerMsg(msgType string, msg interface{}, clusterID string, nodeID string, signer consensus.Signer) (*PeerMsg, error) {
+	jsonMsg, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	sigMsg, err := attachSignatureMsg(msgType, jsonMsg, clusterID, nodeID, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PeerMsg{Type: msgType, Msg: sigMsg}, nil
+}
+
+// Create an empty message for the given message type.
+func newMsgOfType(msgType string) interface{} {
+	switch msgType {
+	case "/req":
+		return &consensus.RequestMsg{}
+	case "/preprepare":
+		return &consensus.PrePrepareMsg{}
+	case "/prepare", "/commit":
+		return &consensus.VoteMsg{}
+	case "/reply":
+		return &consensus.ReplyMsg{}
+	case "/checkpoint":
+		return &consensus.CheckPointMsg{}
+	case "/viewchange":
+		return &consensus.ViewChangeMsg{}
+	case "/newview":
+		return &consensus.NewViewMsg{}
+	}
+
+	return nil
+}
+
+// Check the message type (e.g., "/commit") matches the message.
+func msgOfType(msgType string, msg interface{}) bool {
+	switch m := msg.(type) {
+	case *consensus.RequestMsg:
+		return msgType == "/req"
+	case *consensus.PrePrepareMsg:
+		return msgType == "/preprepare"
+	case *consensus.VoteMsg:
+		return (msgType == "/prepare" && m.MsgType == consensus.PrepareMsg) ||
+		       (msgType == "/commit" && m.MsgType == consensus.CommitMsg)
+	case *consensus.ReplyMsg:
+		return msgType == "/reply"
+	case *consensus.CheckPointMsg:
+		return msgType == "/checkpoint"
+	case *consensus.ViewChangeMsg:
+		return msgType == "/viewchange"
+	case *consensus.NewViewMsg:
+		return msgType == "/newview"
+	}
+
+	return false
+}
@@ -3,8 +3,9 @@ package network
 import (
 	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
 	"fmt"
-	//"time"
+	"sort"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -127,7 +128,8 @@ func (node *Node) fillNewViewMsg(newViewMsg *consensus.NewViewMsg) (int64, int64
 			}
 			if newMap[seq] == nil {
 				digest := setpm.PrePrepareMsg.Digest
-				newMap[seq] = GetPrePrepareForNewview(newViewMsg.NextViewID, seq, digest)
+				request := setpm.PrePrepareMsg.RequestMsg
+				newMap[seq] = GetPrePrepareForNewview(newViewMsg.NextViewID, seq, digest, request)
 			}
 		}
 	}
@@ -163,6 +165,9 @@ func (node *Node) GetNewView(newviewMsg *consensus.NewViewMsg) error {
 	// Accept messages usign MsgEntrance channel
 	node.IsViewChanging = false
 
+	// Order the requests the old primary did not.
+	node.resumePendingRequests()
+
 
 	// verify view number of new-view massage
 	if newviewMsg.NextViewID != node.View.ID + 1 {
@@ -172,78 +177,119 @@ func (node *Node) GetNewView(newviewMsg *consensus.NewViewMsg) error {
 	return nil
 }
 
+// From TOCS: A backup accepts the NEW-VIEW message, adds the
+// PRE-PREPARE messages in O to its log, and multicasts a PREPARE for
+// each of them. The requests run through prepare and commit in the new
+// view, and are executed in order once they commit (see executeMsg);
+// those this replica has executed already are not executed again, but
+// it still votes for them so that the others can commit.
 func (node *Node) FillHole(newviewMsg *consensus.NewViewMsg) {
-	// Check the number of states
-	fmt.Println("node.TotalConsensus :  ",node.TotalConsensus)
-
 	fmt.Println("newviewMsg.Min_S : ", newviewMsg.Min_S)
 	fmt.Println("newviewMsg.Max_S : ", newviewMsg.Max_S)
 
-	// Currunt Max sequence number of committed request
-	var committedMax int64 = 0
-	for seq, _ := range node.CommittedMsgs{
-		if committedMax <= int64(seq+1) {
-			committedMax = int64(seq+1)
+	// if highest sequence number of received request and state is lower than min-s,
+	// node.TotalConsensus be added util min-s - 1
+	node.raiseTotalConsensus(newviewMsg.Min_S)
+
+	// Install the PRE-PREPARE messages in order of their sequence
+	// numbers, each with the request whose digest it names.
+	sequenceIDs := make([]int64, 0, len(newviewMsg.SetPrePrepareMsgs))
+	for seq := range newviewMsg.SetPrePrepareMsgs {
+		sequenceIDs = append(sequenceIDs, seq)
+	}
+	sort.Slice(sequenceIDs, func(i, j int) bool {
+		return sequenceIDs[i] < sequenceIDs[j]
+	})
+
+	var installed []consensus.PBFT
+	var errs []error
+	node.StatesMutex.Lock()
+	for _, seq := range sequenceIDs {
+		prePrepareMsg := newviewMsg.SetPrePrepareMsgs[seq]
+		if prePrepareMsg == nil || prePrepareMsg.SequenceID != seq || prePrepareMsg.ViewID != newviewMsg.NextViewID {
+			errs = append(errs, fmt.Errorf("new-view message for view %d has a corrupted pre-prepare message for sequence number %d",
+			                               newviewMsg.NextViewID, seq))
+			continue
+		}
+
+		request, err := node.newViewRequest(prePrepareMsg, node.States[seq])
+		if err != nil {
+			errs = append(errs, err)
+			continue
 		}
+
+		state := node.createState(request.Timestamp)
+		state.SetSequenceID(seq)
+		state.SetReqMsg(request)
+		state.SetDigest(prePrepareMsg.Digest)
+		node.States[seq] = state
+		installed = append(installed, state)
+
+		node.raiseTotalConsensus(seq)
 	}
-	fmt.Println("committedMax : ", committedMax)
 
-	// if highest sequence number of received request and state is lower than min-s,
-	// node.TotalConsensus be added util min-s - 1
-	for node.TotalConsensus < newviewMsg.Min_S {
-		atomic.AddInt64(&node.TotalConsensus, 1)
+	// From TOCS: Requests pre-prepared in the old view but not in O
+	// are discarded; the new primary orders them again.
+	for seq, state := range node.States {
+		prePrepareMsg := state.GetPrePrepareMsg()
+		if seq > newviewMsg.Min_S && newviewMsg.SetPrePrepareMsgs[seq] == nil &&
+		   (prePrepareMsg == nil || prePrepareMsg.ViewID < newviewMsg.NextViewID) &&
+		   state.GetStage() != consensus.Committed {
+			delete(node.States, seq)
+		}
+	}
+	node.StatesMutex.Unlock()
+
+	if len(errs) > 0 {
+		node.MsgError <- errs
 	}
 
-	// Fill the PrePrepare message from new-view message
-	for seq, prePrepareMsg := range newviewMsg.SetPrePrepareMsgs {
-		fmt.Println("newview seq : ", seq)
+	for _, state := range installed {
+		state.GetMsgSendChannel() <- newviewMsg.SetPrePrepareMsgs[state.GetSequenceID()]
+		go node.startTransitionWithDeadline(state, time.Now().UnixNano())
+	}
+}
 
-		// preprepare.sequenceID could be higher than Max_S of SetPrePare
-		//if seq > newviewMsg.Max_S {
+// Request of a PRE-PREPARE message in O: the one it carries, or that
+// of the state of this replica for the sequence number. Either must be
+// signed by its client and match the digest in the message.
+func (node *Node) newViewRequest(prePrepareMsg *consensus.PrePrepareMsg, state consensus.PBFT) (*consensus.RequestMsg, error) {
+	candidates := []*consensus.RequestMsg{prePrepareMsg.RequestMsg}
+	if state != nil {
+		candidates = append(candidates, state.GetReqMsg())
+	}
 
-		//	continue
-		//}
+	for _, candidate := range candidates {
+		if candidate == nil {
+			continue
+		}
 
-		// node.StatesMutex.Lock()
-		var state consensus.PBFT
-		state, err := node.getState(seq)
-		if err != nil {
-			// Print error.
+		request := *candidate
+		request.SequenceID = prePrepareMsg.SequenceID
+		if request.Digest() != prePrepareMsg.Digest {
+			continue
 		}
-		if state != nil {
-			// Fill the committedMax if it is not committed
-			if seq > committedMax {
-				fmt.Println("no request in node.CommittedMsgs")
-				node.CommittedMsgs = append(node.CommittedMsgs, state.GetReqMsg())
-			}
-			// Initalize all of logs of this state
-			state.ClearMsgLogs()
-
-			// Change the viewid, preprepare message and preprepare message's digest of the state
-			node.States[seq] = state.Redo_SetState(newviewMsg.NextViewID, node.MyInfo.NodeID, len(node.NodeTable), prePrepareMsg, prePrepareMsg.Digest)
-
-		} else { //if this node does not have state and a request with sequence number n (prePrepareMsg.SequenceID)
-			// Fill the state of the sequence number of prePrepareMsg
-			state := node.createState(0)
-
-			state.SetSequenceID(prePrepareMsg.SequenceID)
-			// Log REQUEST message.
-			var request consensus.RequestMsg
-			request.SequenceID = seq
-			request.Operation = ""
-			request.Timestamp = int64(0)
-			request.Data = ""
-			request.ClientID = ""
-			state.SetReqMsg(&request)
-
-			// Fill request message in the committedMax
-			node.CommittedMsgs = append(node.CommittedMsgs, state.GetReqMsg())
-			// Change the viewid, preprepare message and preprepare message's digest of the state
-			node.States[seq] = state.Redo_SetState(newviewMsg.NextViewID, node.MyInfo.NodeID, len(node.NodeTable), prePrepareMsg, prePrepareMsg.Digest)
-
-			atomic.AddInt64(&node.TotalConsensus, 1)
+		if err := node.verifyRequest(&request); err != nil {
+			return nil, fmt.Errorf("pre-prepare message for sequence number %d of view %d: %v",
+			                       prePrepareMsg.SequenceID, prePrepareMsg.ViewID, err)
+		}
+
+		return &request, nil
+	}
+
+	return nil, fmt.Errorf("no request with digest %s for sequence number %d of view %d",
+	                       prePrepareMsg.Digest, prePrepareMsg.SequenceID, prePrepareMsg.ViewID)
+}
+
+// Continue after the given sequence number if this node becomes the
+// primary.
+func (node *Node) raiseTotalConsensus(sequenceID int64) {
+	for {
+		total := atomic.LoadInt64(&node.TotalConsensus)
+		if total >= sequenceID ||
+		   atomic.CompareAndSwapInt64(&node.TotalConsensus, total, sequenceID) {
+			break
 		}
-		//node.StatesMutex.Unlock()
 	}
 }
 
@@ -256,16 +302,22 @@ func (node *Node) isMyNodePrimary() bool {
 	return node.MyInfo.NodeID == node.View.Primary.NodeID
 }
 
+// Primary of the view, or nil for a negative view.
 func (node *Node) getPrimaryInfoByID(viewID int64) *NodeInfo {
+	if viewID < 0 {
+		return nil
+	}
+
 	viewIdx := viewID % int64(len(node.NodeTable))
 	return node.NodeTable[viewIdx]
 }
 
-func GetPrePrepareForNewview(nextviewID int64, sequenceid int64, digest string) *consensus.PrePrepareMsg {
+func GetPrePrepareForNewview(nextviewID int64, sequenceid int64, digest string, request *consensus.RequestMsg) *consensus.PrePrepareMsg {
 	return &consensus.PrePrepareMsg{
 		ViewID:     nextviewID,
 		SequenceID: sequenceid,
 		Digest:     digest,
+		RequestMsg: request,
 	}
 }
 
@@ -275,6 +327,12 @@ func (node *Node) CreateSetP() map[int64]*consensus.SetPm {
 
 	node.StatesMutex.RLock()
 	for seqID, state := range node.States {
+		// A state without a PRE-PREPARE message cannot be prepared,
+		// e.g., one a backup created for a PRE-PREPARE being handled.
+		if state.GetPrePrepareMsg() == nil {
+			continue
+		}
+
 		var setPm consensus.SetPm
 		setPm.PrePrepareMsg = state.GetPrePrepareMsg()
 		setPm.PrepareMsgs = state.GetPrepareMsgs()
@@ -0,0 +1,65 @@
+package network
+
+import (
+	"github.com/bigpicturelabs/consensusPBFT/pbft/consensus"
+	"testing"
+	"time"
+)
+
+func TestFillHole(t *testing.T) {
+	node, client := newBackupNode(t)
+	node.MsgOutbound = make(chan *MsgOut, 4)
+	node.MsgExecution = make(chan *MsgPair, 1)
+
+	// Requests of view 0: one prepared at sequence number 1, where
+	// the new view orders another copy, and one pre-prepared at 2.
+	for seq, data := range map[int64]string{1: "k=1", 2: "k=3"} {
+		request := signedRequest(t, client, seq, data)
+		request.SequenceID = seq
+		state := node.createState(request.Timestamp)
+		state.SetSequenceID(seq)
+		state.SetReqMsg(request)
+		state.SetDigest(request.Digest())
+		state.SetPrePrepareMsg(&consensus.PrePrepareMsg{ViewID: 0, SequenceID: seq, Digest: request.Digest()})
+		node.States[seq] = state
+	}
+
+	ordered := prePrepareOf(signedRequest(t, client, 1, "k=2"), 1)
+	ordered.ViewID = 1
+	unknown := &consensus.PrePrepareMsg{ViewID: 1, SequenceID: 3, Digest: "unknown"}
+	node.updateView(1)
+	node.FillHole(&consensus.NewViewMsg{
+		NodeID:            "Node2",
+		NextViewID:        1,
+		SetPrePrepareMsgs: map[int64]*consensus.PrePrepareMsg{1: ordered, 3: unknown},
+		Max_S:             3,
+	})
+
+	state := node.States[1]
+	if state == nil || state.GetReqMsg().Data != "k=2" || state.GetDigest() != ordered.Digest {
+		t.Fatalf("state of sequence number 1 does not order the request of the new view")
+	}
+	if node.States[2] != nil {
+		t.Errorf("state pre-prepared in the old view only is kept")
+	}
+	if node.States[3] != nil {
+		t.Errorf("state is installed without the request of its digest")
+	}
+	if errs := <-node.MsgError; len(errs) != 1 {
+		t.Errorf("%d errors, want 1 for sequence number 3: %v", len(errs), errs)
+	}
+
+	// The request is prepared in the new view, not executed.
+	select {
+	case out := <-node.MsgOutbound:
+		prepareMsg, ok := out.Msg.(*consensus.VoteMsg)
+		if !ok || out.Path != "/prepare" || prepareMsg.ViewID != 1 || prepareMsg.Digest != ordered.Digest {
+			t.Errorf("sent %s %+v, want the PREPARE of view 1", out.Path, out.Msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("PREPARE is not sent in the new view")
+	}
+	if len(node.MsgExecution) != 0 || len(node.CommittedMsgs) != 0 {
+		t.Errorf("request is executed before it commits in the new view")
+	}
+}
@@ -0,0 +1,1288 @@
+// Messages and services of the gRPC transport.
+//
+// Messages mirror the TOCS style messages in the consensus package.
+// Regenerate the Go code with:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative pbft.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: pbft.proto
+
+package pbftpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type MsgType int32
+
+const (
+	MsgType_PREPARE_MSG MsgType = 0
+	MsgType_COMMIT_MSG  MsgType = 1
+)
+
+// Enum value maps for MsgType.
+var (
+	MsgType_name = map[int32]string{
+		0: "PREPARE_MSG",
+		1: "COMMIT_MSG",
+	}
+	MsgType_value = map[string]int32{
+		"PREPARE_MSG": 0,
+		"COMMIT_MSG":  1,
+	}
+)
+
+func (x MsgType) Enum() *MsgType {
+	p := new(MsgType)
+	*p = x
+	return p
+}
+
+func (x MsgType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MsgType) Descriptor() protoreflect.EnumDescriptor {
+	return file_pbft_proto_enumTypes[0].Descriptor()
+}
+
+func (MsgType) Type() protoreflect.EnumType {
+	return &file_pbft_proto_enumTypes[0]
+}
+
+func (x MsgType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MsgType.Descriptor instead.
+func (MsgType) EnumDescriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{0}
+}
+
+type RequestMsg struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp     int64                  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	ClientId      string                 `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Operation     string                 `protobuf:"bytes,3,opt,name=operation,proto3" json:"operation,omitempty"`
+	Data          string                 `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	SequenceId    int64                  `protobuf:"varint,5,opt,name=sequence_id,json=sequenceId,proto3" json:"sequence_id,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,6,opt,name=signature,proto3" json:"signature,omitempty"` // by the client
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestMsg) Reset() {
+	*x = RequestMsg{}
+	mi := &file_pbft_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestMsg) ProtoMessage() {}
+
+func (x *RequestMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestMsg.ProtoReflect.Descriptor instead.
+func (*RequestMsg) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RequestMsg) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *RequestMsg) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *RequestMsg) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *RequestMsg) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *RequestMsg) GetSequenceId() int64 {
+	if x != nil {
+		return x.SequenceId
+	}
+	return 0
+}
+
+func (x *RequestMsg) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type ReplyMsg struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ViewId        int64                  `protobuf:"varint,1,opt,name=view_id,json=viewId,proto3" json:"view_id,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // same timestamp value as RequestMsg
+	ClientId      string                 `protobuf:"bytes,3,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	NodeId        string                 `protobuf:"bytes,4,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Result        string                 `protobuf:"bytes,5,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReplyMsg) Reset() {
+	*x = ReplyMsg{}
+	mi := &file_pbft_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReplyMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplyMsg) ProtoMessage() {}
+
+func (x *ReplyMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplyMsg.ProtoReflect.Descriptor instead.
+func (*ReplyMsg) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ReplyMsg) GetViewId() int64 {
+	if x != nil {
+		return x.ViewId
+	}
+	return 0
+}
+
+func (x *ReplyMsg) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *ReplyMsg) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *ReplyMsg) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *ReplyMsg) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+type PrePrepareMsg struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ViewId        int64                  `protobuf:"varint,1,opt,name=view_id,json=viewId,proto3" json:"view_id,omitempty"`
+	SequenceId    int64                  `protobuf:"varint,2,opt,name=sequence_id,json=sequenceId,proto3" json:"sequence_id,omitempty"`
+	Digest        string                 `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`                           // of the request
+	RequestMsg    *RequestMsg            `protobuf:"bytes,6,opt,name=request_msg,json=requestMsg,proto3" json:"request_msg,omitempty"` // piggybacked
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PrePrepareMsg) Reset() {
+	*x = PrePrepareMsg{}
+	mi := &file_pbft_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrePrepareMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrePrepareMsg) ProtoMessage() {}
+
+func (x *PrePrepareMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrePrepareMsg.ProtoReflect.Descriptor instead.
+func (*PrePrepareMsg) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PrePrepareMsg) GetViewId() int64 {
+	if x != nil {
+		return x.ViewId
+	}
+	return 0
+}
+
+func (x *PrePrepareMsg) GetSequenceId() int64 {
+	if x != nil {
+		return x.SequenceId
+	}
+	return 0
+}
+
+func (x *PrePrepareMsg) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+func (x *PrePrepareMsg) GetRequestMsg() *RequestMsg {
+	if x != nil {
+		return x.RequestMsg
+	}
+	return nil
+}
+
+type VoteMsg struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ViewId        int64                  `protobuf:"varint,1,opt,name=view_id,json=viewId,proto3" json:"view_id,omitempty"`
+	SequenceId    int64                  `protobuf:"varint,2,opt,name=sequence_id,json=sequenceId,proto3" json:"sequence_id,omitempty"`
+	Digest        string                 `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`
+	NodeId        string                 `protobuf:"bytes,4,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	MsgType       MsgType                `protobuf:"varint,5,opt,name=msg_type,json=msgType,proto3,enum=pbft.MsgType" json:"msg_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VoteMsg) Reset() {
+	*x = VoteMsg{}
+	mi := &file_pbft_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VoteMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VoteMsg) ProtoMessage() {}
+
+func (x *VoteMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VoteMsg.ProtoReflect.Descriptor instead.
+func (*VoteMsg) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *VoteMsg) GetViewId() int64 {
+	if x != nil {
+		return x.ViewId
+	}
+	return 0
+}
+
+func (x *VoteMsg) GetSequenceId() int64 {
+	if x != nil {
+		return x.SequenceId
+	}
+	return 0
+}
+
+func (x *VoteMsg) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+func (x *VoteMsg) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *VoteMsg) GetMsgType() MsgType {
+	if x != nil {
+		return x.MsgType
+	}
+	return MsgType_PREPARE_MSG
+}
+
+type CheckPointMsg struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SequenceId    int64                  `protobuf:"varint,1,opt,name=sequence_id,json=sequenceId,proto3" json:"sequence_id,omitempty"`
+	Digest        string                 `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+	NodeId        string                 `protobuf:"bytes,3,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckPointMsg) Reset() {
+	*x = CheckPointMsg{}
+	mi := &file_pbft_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckPointMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckPointMsg) ProtoMessage() {}
+
+func (x *CheckPointMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckPointMsg.ProtoReflect.Descriptor instead.
+func (*CheckPointMsg) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CheckPointMsg) GetSequenceId() int64 {
+	if x != nil {
+		return x.SequenceId
+	}
+	return 0
+}
+
+func (x *CheckPointMsg) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+func (x *CheckPointMsg) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+type SetPm struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PrePrepareMsg *PrePrepareMsg         `protobuf:"bytes,1,opt,name=pre_prepare_msg,json=prePrepareMsg,proto3" json:"pre_prepare_msg,omitempty"`
+	PrepareMsgs   map[string]*VoteMsg    `protobuf:"bytes,2,rep,name=prepare_msgs,json=prepareMsgs,proto3" json:"prepare_msgs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetPm) Reset() {
+	*x = SetPm{}
+	mi := &file_pbft_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetPm) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetPm) ProtoMessage() {}
+
+func (x *SetPm) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetPm.ProtoReflect.Descriptor instead.
+func (*SetPm) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SetPm) GetPrePrepareMsg() *PrePrepareMsg {
+	if x != nil {
+		return x.PrePrepareMsg
+	}
+	return nil
+}
+
+func (x *SetPm) GetPrepareMsgs() map[string]*VoteMsg {
+	if x != nil {
+		return x.PrepareMsgs
+	}
+	return nil
+}
+
+type ViewChangeMsg struct {
+	state            protoimpl.MessageState    `protogen:"open.v1"`
+	NodeId           string                    `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	NextViewId       int64                     `protobuf:"varint,2,opt,name=next_view_id,json=nextViewId,proto3" json:"next_view_id,omitempty"`
+	StableCheckPoint int64                     `protobuf:"varint,3,opt,name=stable_check_point,json=stableCheckPoint,proto3" json:"stable_check_point,omitempty"`
+	SetC             map[string]*CheckPointMsg `protobuf:"bytes,4,rep,name=set_c,json=setC,proto3" json:"set_c,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	SetP             map[int64]*SetPm          `protobuf:"bytes,5,rep,name=set_p,json=setP,proto3" json:"set_p,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *ViewChangeMsg) Reset() {
+	*x = ViewChangeMsg{}
+	mi := &file_pbft_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ViewChangeMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ViewChangeMsg) ProtoMessage() {}
+
+func (x *ViewChangeMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ViewChangeMsg.ProtoReflect.Descriptor instead.
+func (*ViewChangeMsg) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ViewChangeMsg) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *ViewChangeMsg) GetNextViewId() int64 {
+	if x != nil {
+		return x.NextViewId
+	}
+	return 0
+}
+
+func (x *ViewChangeMsg) GetStableCheckPoint() int64 {
+	if x != nil {
+		return x.StableCheckPoint
+	}
+	return 0
+}
+
+func (x *ViewChangeMsg) GetSetC() map[string]*CheckPointMsg {
+	if x != nil {
+		return x.SetC
+	}
+	return nil
+}
+
+func (x *ViewChangeMsg) GetSetP() map[int64]*SetPm {
+	if x != nil {
+		return x.SetP
+	}
+	return nil
+}
+
+type NewViewMsg struct {
+	state             protoimpl.MessageState    `protogen:"open.v1"`
+	NodeId            string                    `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	NextViewId        int64                     `protobuf:"varint,2,opt,name=next_view_id,json=nextViewId,proto3" json:"next_view_id,omitempty"`
+	SetViewChangeMsgs map[string]*ViewChangeMsg `protobuf:"bytes,3,rep,name=set_view_change_msgs,json=setViewChangeMsgs,proto3" json:"set_view_change_msgs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	SetPrePrepareMsgs map[int64]*PrePrepareMsg  `protobuf:"bytes,4,rep,name=set_pre_prepare_msgs,json=setPrePrepareMsgs,proto3" json:"set_pre_prepare_msgs,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	MaxS              int64                     `protobuf:"varint,5,opt,name=max_s,json=maxS,proto3" json:"max_s,omitempty"`
+	MinS              int64                     `protobuf:"varint,6,opt,name=min_s,json=minS,proto3" json:"min_s,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *NewViewMsg) Reset() {
+	*x = NewViewMsg{}
+	mi := &file_pbft_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NewViewMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewViewMsg) ProtoMessage() {}
+
+func (x *NewViewMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewViewMsg.ProtoReflect.Descriptor instead.
+func (*NewViewMsg) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *NewViewMsg) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *NewViewMsg) GetNextViewId() int64 {
+	if x != nil {
+		return x.NextViewId
+	}
+	return 0
+}
+
+func (x *NewViewMsg) GetSetViewChangeMsgs() map[string]*ViewChangeMsg {
+	if x != nil {
+		return x.SetViewChangeMsgs
+	}
+	return nil
+}
+
+func (x *NewViewMsg) GetSetPrePrepareMsgs() map[int64]*PrePrepareMsg {
+	if x != nil {
+		return x.SetPrePrepareMsgs
+	}
+	return nil
+}
+
+func (x *NewViewMsg) GetMaxS() int64 {
+	if x != nil {
+		return x.MaxS
+	}
+	return 0
+}
+
+func (x *NewViewMsg) GetMinS() int64 {
+	if x != nil {
+		return x.MinS
+	}
+	return 0
+}
+
+// A consensus message on a replica stream. The signature covers the
+// version, cluster ID, sender and message (see consensus.SigningBytes),
+// but not the epoch and sequence number.
+type Envelope struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Version   uint32                 `protobuf:"varint,12,opt,name=version,proto3" json:"version,omitempty"`
+	ClusterId string                 `protobuf:"bytes,13,opt,name=cluster_id,json=clusterId,proto3" json:"cluster_id,omitempty"`
+	Sender    string                 `protobuf:"bytes,1,opt,name=sender,proto3" json:"sender,omitempty"`
+	// Sequence numbers restart with the epoch when the sender restarts.
+	Epoch int64  `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Seq   uint64 `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	// Types that are valid to be assigned to Msg:
+	//
+	//	*Envelope_Request
+	//	*Envelope_PrePrepare
+	//	*Envelope_Prepare
+	//	*Envelope_Commit
+	//	*Envelope_Reply
+	//	*Envelope_Checkpoint
+	//	*Envelope_ViewChange
+	//	*Envelope_NewView
+	Msg           isEnvelope_Msg `protobuf_oneof:"msg"`
+	Signature     []byte         `protobuf:"bytes,15,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Envelope) Reset() {
+	*x = Envelope{}
+	mi := &file_pbft_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Envelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Envelope) ProtoMessage() {}
+
+func (x *Envelope) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Envelope) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Envelope) GetClusterId() string {
+	if x != nil {
+		return x.ClusterId
+	}
+	return ""
+}
+
+func (x *Envelope) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *Envelope) GetEpoch() int64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *Envelope) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Envelope) GetMsg() isEnvelope_Msg {
+	if x != nil {
+		return x.Msg
+	}
+	return nil
+}
+
+func (x *Envelope) GetRequest() *RequestMsg {
+	if x != nil {
+		if x, ok := x.Msg.(*Envelope_Request); ok {
+			return x.Request
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetPrePrepare() *PrePrepareMsg {
+	if x != nil {
+		if x, ok := x.Msg.(*Envelope_PrePrepare); ok {
+			return x.PrePrepare
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetPrepare() *VoteMsg {
+	if x != nil {
+		if x, ok := x.Msg.(*Envelope_Prepare); ok {
+			return x.Prepare
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetCommit() *VoteMsg {
+	if x != nil {
+		if x, ok := x.Msg.(*Envelope_Commit); ok {
+			return x.Commit
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetReply() *ReplyMsg {
+	if x != nil {
+		if x, ok := x.Msg.(*Envelope_Reply); ok {
+			return x.Reply
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetCheckpoint() *CheckPointMsg {
+	if x != nil {
+		if x, ok := x.Msg.(*Envelope_Checkpoint); ok {
+			return x.Checkpoint
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetViewChange() *ViewChangeMsg {
+	if x != nil {
+		if x, ok := x.Msg.(*Envelope_ViewChange); ok {
+			return x.ViewChange
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetNewView() *NewViewMsg {
+	if x != nil {
+		if x, ok := x.Msg.(*Envelope_NewView); ok {
+			return x.NewView
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type isEnvelope_Msg interface {
+	isEnvelope_Msg()
+}
+
+type Envelope_Request struct {
+	Request *RequestMsg `protobuf:"bytes,4,opt,name=request,proto3,oneof"`
+}
+
+type Envelope_PrePrepare struct {
+	PrePrepare *PrePrepareMsg `protobuf:"bytes,5,opt,name=pre_prepare,json=prePrepare,proto3,oneof"`
+}
+
+type Envelope_Prepare struct {
+	Prepare *VoteMsg `protobuf:"bytes,6,opt,name=prepare,proto3,oneof"`
+}
+
+type Envelope_Commit struct {
+	Commit *VoteMsg `protobuf:"bytes,7,opt,name=commit,proto3,oneof"`
+}
+
+type Envelope_Reply struct {
+	Reply *ReplyMsg `protobuf:"bytes,8,opt,name=reply,proto3,oneof"`
+}
+
+type Envelope_Checkpoint struct {
+	Checkpoint *CheckPointMsg `protobuf:"bytes,9,opt,name=checkpoint,proto3,oneof"`
+}
+
+type Envelope_ViewChange struct {
+	ViewChange *ViewChangeMsg `protobuf:"bytes,10,opt,name=view_change,json=viewChange,proto3,oneof"`
+}
+
+type Envelope_NewView struct {
+	NewView *NewViewMsg `protobuf:"bytes,11,opt,name=new_view,json=newView,proto3,oneof"`
+}
+
+func (*Envelope_Request) isEnvelope_Msg() {}
+
+func (*Envelope_PrePrepare) isEnvelope_Msg() {}
+
+func (*Envelope_Prepare) isEnvelope_Msg() {}
+
+func (*Envelope_Commit) isEnvelope_Msg() {}
+
+func (*Envelope_Reply) isEnvelope_Msg() {}
+
+func (*Envelope_Checkpoint) isEnvelope_Msg() {}
+
+func (*Envelope_ViewChange) isEnvelope_Msg() {}
+
+func (*Envelope_NewView) isEnvelope_Msg() {}
+
+// Cumulative acknowledgement of the messages of an epoch.
+type Ack struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Epoch         int64                  `protobuf:"varint,1,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Seq           uint64                 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ack) Reset() {
+	*x = Ack{}
+	mi := &file_pbft_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ack) ProtoMessage() {}
+
+func (x *Ack) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ack.ProtoReflect.Descriptor instead.
+func (*Ack) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Ack) GetEpoch() int64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *Ack) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+type SubmitReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      bool                   `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	NodeId        string                 `protobuf:"bytes,2,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitReply) Reset() {
+	*x = SubmitReply{}
+	mi := &file_pbft_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitReply) ProtoMessage() {}
+
+func (x *SubmitReply) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitReply.ProtoReflect.Descriptor instead.
+func (*SubmitReply) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SubmitReply) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *SubmitReply) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+type NonceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NonceRequest) Reset() {
+	*x = NonceRequest{}
+	mi := &file_pbft_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NonceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NonceRequest) ProtoMessage() {}
+
+func (x *NonceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NonceRequest.ProtoReflect.Descriptor instead.
+func (*NonceRequest) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{11}
+}
+
+type NonceReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nonce         string                 `protobuf:"bytes,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NonceReply) Reset() {
+	*x = NonceReply{}
+	mi := &file_pbft_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NonceReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NonceReply) ProtoMessage() {}
+
+func (x *NonceReply) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NonceReply.ProtoReflect.Descriptor instead.
+func (*NonceReply) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *NonceReply) GetNonce() string {
+	if x != nil {
+		return x.Nonce
+	}
+	return ""
+}
+
+var File_pbft_proto protoreflect.FileDescriptor
+
+const file_pbft_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"pbft.proto\x12\x04pbft\"\xb8\x01\n" +
+	"\n" +
+	"RequestMsg\x12\x1c\n" +
+	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\x12\x1b\n" +
+	"\tclient_id\x18\x02 \x01(\tR\bclientId\x12\x1c\n" +
+	"\toperation\x18\x03 \x01(\tR\toperation\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\tR\x04data\x12\x1f\n" +
+	"\vsequence_id\x18\x05 \x01(\x03R\n" +
+	"sequenceId\x12\x1c\n" +
+	"\tsignature\x18\x06 \x01(\fR\tsignature\"\x8f\x01\n" +
+	"\bReplyMsg\x12\x17\n" +
+	"\aview_id\x18\x01 \x01(\x03R\x06viewId\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12\x1b\n" +
+	"\tclient_id\x18\x03 \x01(\tR\bclientId\x12\x17\n" +
+	"\anode_id\x18\x04 \x01(\tR\x06nodeId\x12\x16\n" +
+	"\x06result\x18\x05 \x01(\tR\x06result\"\xa0\x01\n" +
+	"\rPrePrepareMsg\x12\x17\n" +
+	"\aview_id\x18\x01 \x01(\x03R\x06viewId\x12\x1f\n" +
+	"\vsequence_id\x18\x02 \x01(\x03R\n" +
+	"sequenceId\x12\x16\n" +
+	"\x06digest\x18\x03 \x01(\tR\x06digest\x121\n" +
+	"\vrequest_msg\x18\x06 \x01(\v2\x10.pbft.RequestMsgR\n" +
+	"requestMsgJ\x04\b\x04\x10\x05J\x04\b\x05\x10\x06\"\x9e\x01\n" +
+	"\aVoteMsg\x12\x17\n" +
+	"\aview_id\x18\x01 \x01(\x03R\x06viewId\x12\x1f\n" +
+	"\vsequence_id\x18\x02 \x01(\x03R\n" +
+	"sequenceId\x12\x16\n" +
+	"\x06digest\x18\x03 \x01(\tR\x06digest\x12\x17\n" +
+	"\anode_id\x18\x04 \x01(\tR\x06nodeId\x12(\n" +
+	"\bmsg_type\x18\x05 \x01(\x0e2\r.pbft.MsgTypeR\amsgType\"a\n" +
+	"\rCheckPointMsg\x12\x1f\n" +
+	"\vsequence_id\x18\x01 \x01(\x03R\n" +
+	"sequenceId\x12\x16\n" +
+	"\x06digest\x18\x02 \x01(\tR\x06digest\x12\x17\n" +
+	"\anode_id\x18\x03 \x01(\tR\x06nodeId\"\xd4\x01\n" +
+	"\x05SetPm\x12;\n" +
+	"\x0fpre_prepare_msg\x18\x01 \x01(\v2\x13.pbft.PrePrepareMsgR\rprePrepareMsg\x12?\n" +
+	"\fprepare_msgs\x18\x02 \x03(\v2\x1c.pbft.SetPm.PrepareMsgsEntryR\vprepareMsgs\x1aM\n" +
+	"\x10PrepareMsgsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12#\n" +
+	"\x05value\x18\x02 \x01(\v2\r.pbft.VoteMsgR\x05value:\x028\x01\"\xf4\x02\n" +
+	"\rViewChangeMsg\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12 \n" +
+	"\fnext_view_id\x18\x02 \x01(\x03R\n" +
+	"nextViewId\x12,\n" +
+	"\x12stable_check_point\x18\x03 \x01(\x03R\x10stableCheckPoint\x122\n" +
+	"\x05set_c\x18\x04 \x03(\v2\x1d.pbft.ViewChangeMsg.SetCEntryR\x04setC\x122\n" +
+	"\x05set_p\x18\x05 \x03(\v2\x1d.pbft.ViewChangeMsg.SetPEntryR\x04setP\x1aL\n" +
+	"\tSetCEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12)\n" +
+	"\x05value\x18\x02 \x01(\v2\x13.pbft.CheckPointMsgR\x05value:\x028\x01\x1aD\n" +
+	"\tSetPEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12!\n" +
+	"\x05value\x18\x02 \x01(\v2\v.pbft.SetPmR\x05value:\x028\x01\"\xdb\x03\n" +
+	"\n" +
+	"NewViewMsg\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12 \n" +
+	"\fnext_view_id\x18\x02 \x01(\x03R\n" +
+	"nextViewId\x12X\n" +
+	"\x14set_view_change_msgs\x18\x03 \x03(\v2'.pbft.NewViewMsg.SetViewChangeMsgsEntryR\x11setViewChangeMsgs\x12X\n" +
+	"\x14set_pre_prepare_msgs\x18\x04 \x03(\v2'.pbft.NewViewMsg.SetPrePrepareMsgsEntryR\x11setPrePrepareMsgs\x12\x13\n" +
+	"\x05max_s\x18\x05 \x01(\x03R\x04maxS\x12\x13\n" +
+	"\x05min_s\x18\x06 \x01(\x03R\x04minS\x1aY\n" +
+	"\x16SetViewChangeMsgsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12)\n" +
+	"\x05value\x18\x02 \x01(\v2\x13.pbft.ViewChangeMsgR\x05value:\x028\x01\x1aY\n" +
+	"\x16SetPrePrepareMsgsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12)\n" +
+	"\x05value\x18\x02 \x01(\v2\x13.pbft.PrePrepareMsgR\x05value:\x028\x01\"\xa8\x04\n" +
+	"\bEnvelope\x12\x18\n" +
+	"\aversion\x18\f \x01(\rR\aversion\x12\x1d\n" +
+	"\n" +
+	"cluster_id\x18\r \x01(\tR\tclusterId\x12\x16\n" +
+	"\x06sender\x18\x01 \x01(\tR\x06sender\x12\x14\n" +
+	"\x05epoch\x18\x02 \x01(\x03R\x05epoch\x12\x10\n" +
+	"\x03seq\x18\x03 \x01(\x04R\x03seq\x12,\n" +
+	"\arequest\x18\x04 \x01(\v2\x10.pbft.RequestMsgH\x00R\arequest\x126\n" +
+	"\vpre_prepare\x18\x05 \x01(\v2\x13.pbft.PrePrepareMsgH\x00R\n" +
+	"prePrepare\x12)\n" +
+	"\aprepare\x18\x06 \x01(\v2\r.pbft.VoteMsgH\x00R\aprepare\x12'\n" +
+	"\x06commit\x18\a \x01(\v2\r.pbft.VoteMsgH\x00R\x06commit\x12&\n" +
+	"\x05reply\x18\b \x01(\v2\x0e.pbft.ReplyMsgH\x00R\x05reply\x125\n" +
+	"\n" +
+	"checkpoint\x18\t \x01(\v2\x13.pbft.CheckPointMsgH\x00R\n" +
+	"checkpoint\x126\n" +
+	"\vview_change\x18\n" +
+	" \x01(\v2\x13.pbft.ViewChangeMsgH\x00R\n" +
+	"viewChange\x12-\n" +
+	"\bnew_view\x18\v \x01(\v2\x10.pbft.NewViewMsgH\x00R\anewView\x12\x1c\n" +
+	"\tsignature\x18\x0f \x01(\fR\tsignatureB\x05\n" +
+	"\x03msg\"-\n" +
+	"\x03Ack\x12\x14\n" +
+	"\x05epoch\x18\x01 \x01(\x03R\x05epoch\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x04R\x03seq\"B\n" +
+	"\vSubmitReply\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\bR\baccepted\x12\x17\n" +
+	"\anode_id\x18\x02 \x01(\tR\x06nodeId\"\x0e\n" +
+	"\fNonceRequest\"\"\n" +
+	"\n" +
+	"NonceReply\x12\x14\n" +
+	"\x05nonce\x18\x01 \x01(\tR\x05nonce**\n" +
+	"\aMsgType\x12\x0f\n" +
+	"\vPREPARE_MSG\x10\x00\x12\x0e\n" +
+	"\n" +
+	"COMMIT_MSG\x10\x0122\n" +
+	"\aReplica\x12'\n" +
+	"\x06Stream\x12\x0e.pbft.Envelope\x1a\t.pbft.Ack(\x010\x0127\n" +
+	"\x06Client\x12-\n" +
+	"\x06Submit\x12\x10.pbft.RequestMsg\x1a\x11.pbft.SubmitReply2:\n" +
+	"\tHandshake\x12-\n" +
+	"\x05Nonce\x12\x12.pbft.NonceRequest\x1a\x10.pbft.NonceReplyB5Z3github.com/bigpicturelabs/consensusPBFT/pbft/pbftpbb\x06proto3"
+
+var (
+	file_pbft_proto_rawDescOnce sync.Once
+	file_pbft_proto_rawDescData []byte
+)
+
+func file_pbft_proto_rawDescGZIP() []byte {
+	file_pbft_proto_rawDescOnce.Do(func() {
+		file_pbft_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pbft_proto_rawDesc), len(file_pbft_proto_rawDesc)))
+	})
+	return file_pbft_proto_rawDescData
+}
+
+var file_pbft_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_pbft_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_pbft_proto_goTypes = []any{
+	(MsgType)(0),          // 0: pbft.MsgType
+	(*RequestMsg)(nil),    // 1: pbft.RequestMsg
+	(*ReplyMsg)(nil),      // 2: pbft.ReplyMsg
+	(*PrePrepareMsg)(nil), // 3: pbft.PrePrepareMsg
+	(*VoteMsg)(nil),       // 4: pbft.VoteMsg
+	(*CheckPointMsg)(nil), // 5: pbft.CheckPointMsg
+	(*SetPm)(nil),         // 6: pbft.SetPm
+	(*ViewChangeMsg)(nil), // 7: pbft.ViewChangeMsg
+	(*NewViewMsg)(nil),    // 8: pbft.NewViewMsg
+	(*Envelope)(nil),      // 9: pbft.Envelope
+	(*Ack)(nil),           // 10: pbft.Ack
+	(*SubmitReply)(nil),   // 11: pbft.SubmitReply
+	(*NonceRequest)(nil),  // 12: pbft.NonceRequest
+	(*NonceReply)(nil),    // 13: pbft.NonceReply
+	nil,                   // 14: pbft.SetPm.PrepareMsgsEntry
+	nil,                   // 15: pbft.ViewChangeMsg.SetCEntry
+	nil,                   // 16: pbft.ViewChangeMsg.SetPEntry
+	nil,                   // 17: pbft.NewViewMsg.SetViewChangeMsgsEntry
+	nil,                   // 18: pbft.NewViewMsg.SetPrePrepareMsgsEntry
+}
+var file_pbft_proto_depIdxs = []int32{
+	1,  // 0: pbft.PrePrepareMsg.request_msg:type_name -> pbft.RequestMsg
+	0,  // 1: pbft.VoteMsg.msg_type:type_name -> pbft.MsgType
+	3,  // 2: pbft.SetPm.pre_prepare_msg:type_name -> pbft.PrePrepareMsg
+	14, // 3: pbft.SetPm.prepare_msgs:type_name -> pbft.SetPm.PrepareMsgsEntry
+	15, // 4: pbft.ViewChangeMsg.set_c:type_name -> pbft.ViewChangeMsg.SetCEntry
+	16, // 5: pbft.ViewChangeMsg.set_p:type_name -> pbft.ViewChangeMsg.SetPEntry
+	17, // 6: pbft.NewViewMsg.set_view_change_msgs:type_name -> pbft.NewViewMsg.SetViewChangeMsgsEntry
+	18, // 7: pbft.NewViewMsg.set_pre_prepare_msgs:type_name -> pbft.NewViewMsg.SetPrePrepareMsgsEntry
+	1,  // 8: pbft.Envelope.request:type_name -> pbft.RequestMsg
+	3,  // 9: pbft.Envelope.pre_prepare:type_name -> pbft.PrePrepareMsg
+	4,  // 10: pbft.Envelope.prepare:type_name -> pbft.VoteMsg
+	4,  // 11: pbft.Envelope.commit:type_name -> pbft.VoteMsg
+	2,  // 12: pbft.Envelope.reply:type_name -> pbft.ReplyMsg
+	5,  // 13: pbft.Envelope.checkpoint:type_name -> pbft.CheckPointMsg
+	7,  // 14: pbft.Envelope.view_change:type_name -> pbft.ViewChangeMsg
+	8,  // 15: pbft.Envelope.new_view:type_name -> pbft.NewViewMsg
+	4,  // 16: pbft.SetPm.PrepareMsgsEntry.value:type_name -> pbft.VoteMsg
+	5,  // 17: pbft.ViewChangeMsg.SetCEntry.value:type_name -> pbft.CheckPointMsg
+	6,  // 18: pbft.ViewChangeMsg.SetPEntry.value:type_name -> pbft.SetPm
+	7,  // 19: pbft.NewViewMsg.SetViewChangeMsgsEntry.value:type_name -> pbft.ViewChangeMsg
+	3,  // 20: pbft.NewViewMsg.SetPrePrepareMsgsEntry.value:type_name -> pbft.PrePrepareMsg
+	9,  // 21: pbft.Replica.Stream:input_type -> pbft.Envelope
+	1,  // 22: pbft.Client.Submit:input_type -> pbft.RequestMsg
+	12, // 23: pbft.Handshake.Nonce:input_type -> pbft.NonceRequest
+	10, // 24: pbft.Replica.Stream:output_type -> pbft.Ack
+	11, // 25: pbft.Client.Submit:output_type -> pbft.SubmitReply
+	13, // 26: pbft.Handshake.Nonce:output_type -> pbft.NonceReply
+	24, // [24:27] is the sub-list for method output_type
+	21, // [21:24] is the sub-list for method input_type
+	21, // [21:21] is the sub-list for extension type_name
+	21, // [21:21] is the sub-list for extension extendee
+	0,  // [0:21] is the sub-list for field type_name
+}
+
+func init() { file_pbft_proto_init() }
+func file_pbft_proto_init() {
+	if File_pbft_proto != nil {
+		return
+	}
+	file_pbft_proto_msgTypes[8].OneofWrappers = []any{
+		(*Envelope_Request)(nil),
+		(*Envelope_PrePrepare)(nil),
+		(*Envelope_Prepare)(nil),
+		(*Envelope_Commit)(nil),
+		(*Envelope_Reply)(nil),
+		(*Envelope_Checkpoint)(nil),
+		(*Envelope_ViewChange)(nil),
+		(*Envelope_NewView)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pbft_proto_rawDesc), len(file_pbft_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   18,
+			NumExtensions: 0,
+			NumServices:   3,
+		},
+		GoTypes:           file_pbft_proto_goTypes,
+		DependencyIndexes: file_pbft_proto_depIdxs,
+		EnumInfos:         file_pbft_proto_enumTypes,
+		MessageInfos:      file_pbft_proto_msgTypes,
+	}.Build()
+	File_pbft_proto = out.File
+	file_pbft_proto_goTypes = nil
+	file_pbft_proto_depIdxs = nil
+}
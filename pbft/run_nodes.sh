@@ -31,7 +31,7 @@ then
 fi
 
 # Build binary file first.
-go build main.go
+go build -o main .
 exitcode=$?
 if [[ $exitcode -ne 0 ]]
 then
@@ -47,14 +47,22 @@ echo "Logs are saved in $LOGPATH"
 echo ""
 echo "Try to spawn $TOTALNODE nodes"
 
-echo `awk -v N=$1 -f nodelist.awk /dev/null` > $NODELISTPATH
+# gRPC addresses let clients submit requests (see send.sh).
+./main nodelist -n $TOTALNODE -grpc-offset 1000 -o $NODELISTPATH
+
+# Clients created by key_gen.sh can send requests.
+CLIENTLISTPATH=""
+if [[ -f client.list ]]
+then
+	CLIENTLISTPATH="client.list"
+fi
 
 for i in `seq 1 $1`
 do
 	nodename="Node$i"
 
 	echo "node $nodename spawned!"
-	(NODENAME=$nodename; ./main $NODENAME $NODELISTPATH 2>&1 > "$LOGPATH/$NODENAME.log") &
+	(NODENAME=$nodename; ./main $NODENAME $NODELISTPATH $CLIENTLISTPATH 2>&1 > "$LOGPATH/$NODENAME.log") &
 done
 
 printf "${RED}$TOTALNODE nodes are running${NC}\n"